@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/big"
 	"os"
 
+	"L0/internal/dto"
+	"L0/internal/envelope"
+	"L0/internal/logging"
+	"L0/internal/tracing"
+
 	stan "github.com/nats-io/stan.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
 func main() {
@@ -19,29 +28,83 @@ func main() {
 		clientID  = flag.String("client", "publisher", "NATS Streaming client ID")
 		subject   = flag.String("subject", "orders", "subject to publish to")
 		natsURL   = flag.String("url", "nats://localhost:4222", "NATS Streaming server URL")
+		format    = flag.String("format", "json", "message format: json or protobuf (sent in an envelope)")
+		traceExp  = flag.String("trace", tracing.ExporterNone, "trace exporter: none, stdout or otlp")
+		otlpAddr  = flag.String("otlp-endpoint", "localhost:4317", "OTLP/gRPC collector address for -trace otlp")
+		logLevel  = flag.String("log-level", "info", "log level: debug, info, warn or error")
 	)
 	flag.Parse() // разбираем переданные флаги
+	if err := logging.Setup(*logLevel); err != nil {
+		logging.Fatal("logging", "err", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName:  "l0-publisher",
+		Exporter:     *traceExp,
+		OTLPEndpoint: *otlpAddr,
+		OTLPInsecure: true,
+	})
+	if err != nil {
+		logging.Fatal("tracing", "err", err)
+	}
+	defer shutdownTracing(context.Background()) // досылаем спан публикации
+
+	// Спан публикации; его контекст уходит в заголовках конверта и продолжается в сервисе.
+	ctx, span := otel.Tracer("L0/cmd/publisher").Start(context.Background(), *subject+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	headers := map[string]string{}
+	tracing.Inject(ctx, headers)
 
 	payload, err := os.ReadFile(*file) // читаем JSON из файла
 	if err != nil {
-		log.Fatalf("read payload: %v", err)
+		logging.Fatal("read payload", "err", err)
 	}
 
 	if !json.Valid(payload) { // проверяем корректность JSON
-		log.Fatal("file does not contain valid JSON")
+		logging.Fatal("file does not contain valid JSON", "file", *file)
+	}
+
+	switch *format {
+	case "json": // без трассировки отправляем файл как есть, иначе — в конверте с traceparent
+		if len(headers) > 0 {
+			if payload, err = envelope.Wrap(envelope.ContentTypeJSON, payload, headers); err != nil {
+				logging.Fatal("wrap json", "err", err)
+			}
+		}
+	case "protobuf":
+		if payload, err = protobufEnvelope(payload, headers); err != nil {
+			logging.Fatal("encode protobuf", "err", err)
+		}
+	default:
+		logging.Fatal("unknown format", "format", *format)
 	}
 
 	sc, err := stan.Connect(*clusterID, fmt.Sprintf("%s-%d", *clientID, randInt()), stan.NatsURL(*natsURL)) // подключаемся к NATS Streaming, добавляя случайный хвост к clientID
 	if err != nil {
-		log.Fatalf("connect: %v", err)
+		logging.Fatal("connect", "err", err)
 	}
 	defer sc.Close() // гарантируем закрытие соединения после завершения
 
 	if err := sc.Publish(*subject, payload); err != nil { // отправляем сообщение
-		log.Fatalf("publish: %v", err)
+		logging.Fatal("publish", "err", err)
 	}
 
-	log.Printf("published %d bytes to %s", len(payload), *subject) // логируем факт отправки и размер
+	slog.Info("published", "bytes", len(payload), "subject", *subject) // логируем факт отправки и размер
+}
+
+// protobufEnvelope перекодирует JSON заказа в protobuf и упаковывает его в конверт,
+// по которому сервис определяет формат сообщения.
+func protobufEnvelope(payload []byte, headers map[string]string) ([]byte, error) {
+	var order dto.Order
+	if err := json.Unmarshal(payload, &order); err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(dto.ToProto(order))
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Wrap(envelope.ContentTypeProtobuf, data, headers)
 }
 
 func randInt() int64 { // randInt выдаёт случайное неотрицательное число < 2^31
@@ -3,19 +3,36 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
+	"L0/internal/auth"
 	"L0/internal/cache"
+	"L0/internal/compress"
 	"L0/internal/db"
+	"L0/internal/dto"
+	"L0/internal/fieldcrypt"
+	"L0/internal/grpcapi"
+	"L0/internal/health"
+	"L0/internal/httpapi"
+	"L0/internal/logging"
 	"L0/internal/nats"
+	"L0/internal/outbox"
+	"L0/internal/ratelimit"
 	"L0/internal/service"
+	"L0/internal/tracing"
+	"L0/internal/webhook"
 
 	stan "github.com/nats-io/stan.go"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -23,82 +40,247 @@ const (
 	natsClusterID  = "test-cluster"
 	natsClientID   = "service-1"
 	natsChannel    = "orders"
+	natsURL        = "nats://localhost:4222"
 	httpListenAddr = ":8080"
+	grpcListenAddr = ":9090"
+
+	// natsDurableName — имя durable-подписки: после перезапуска сервис продолжает с первого
+	// неподтверждённого сообщения. natsAckWait — через сколько сервер повторит сообщение,
+	// которое не удалось сохранить. natsDrainTimeout — сколько при остановке ждать
+	// обработки уже полученных сообщений.
+	natsDurableName  = "orders-svc"
+	natsMaxInflight  = 25
+	natsAckWait      = 30 * time.Second
+	natsDrainTimeout = 10 * time.Second
+
+	// Соединение с NATS Streaming считается потерянным после natsPingMaxOut пингов без
+	// ответа (раз в natsPingInterval); затем сервис переподключается и возобновляет
+	// durable-подписку с паузой от natsReconnectWait, удваивая её до natsMaxReconnectWait.
+	natsPingInterval     = 5 * time.Second
+	natsPingMaxOut       = 3
+	natsReconnectWait    = time.Second
+	natsMaxReconnectWait = 30 * time.Second
+
+	// Отставание от канала берётся из мониторинга NATS Streaming раз в natsLagPollInterval;
+	// больше natsLagThreshold необработанных сообщений — сервис degraded (готовность сохраняется).
+	natsMonitorURL      = "http://localhost:8222"
+	natsLagPollInterval = 15 * time.Second
+	natsLagThreshold    = 1000
+
+	// orderEventsSubjectEnv переопределяет канал NATS Streaming, в который из outbox
+	// (таблица order_events) публикуются события created, updated и deleted
+	// (по умолчанию orderEventsSubject). outboxInterval — пауза между опросами outbox,
+	// outboxBatch — сколько событий отправляется за один опрос. Отправленные события
+	// хранятся outboxRetention (дольше, если по ним ещё идёт доставка webhook).
+	orderEventsSubjectEnv = "L0_ORDER_EVENTS_SUBJECT"
+	orderEventsSubject    = "order_events"
+	outboxInterval        = time.Second
+	outboxBatch           = 100
+	outboxRetention       = 7 * 24 * time.Hour
+
+	// Webhook-доставки выбираются из очереди раз в webhookInterval пачками до webhookBatch;
+	// запрос к получателю ограничен webhookTimeout. Неудачная доставка повторяется через
+	// webhookBackoff, затем задержка удваивается до webhookMaxBackoff; после
+	// webhookMaxAttempts попыток доставка помечается failed. Доставленные и неудачные
+	// доставки хранятся webhookRetention.
+	webhookInterval    = time.Second
+	webhookBatch       = 50
+	webhookTimeout     = 5 * time.Second
+	webhookMaxAttempts = 8
+	webhookBackoff     = 10 * time.Second
+	webhookMaxBackoff  = time.Hour
+	webhookRetention   = 7 * 24 * time.Hour
+
+	// cacheSnapshotEnv — путь к снимку кэша, который пишется при остановке и загружается
+	// при старте, чтобы заказы отдавались ещё до окончания прогрева из БД. В снимке
+	// заказы с персональными данными хранятся открытым текстом, поэтому снимок
+	// включается только явно и никогда не используется при включённом шифровании.
+	cacheSnapshotEnv = "L0_CACHE_SNAPSHOT"
+
+	// orderCacheControl разрешает хранить заказ только в кэше браузера клиента (не в
+	// общих кэшах и CDN: в ответе персональные данные, а его вид зависит от профиля
+	// маскирования) и требует ревалидации по ETag/Last-Modified перед каждым использованием.
+	orderCacheControl = "private, no-cache"
+
+	// cachePrecompress хранит в кэше сжатые gzip/zstd копии заказов, чтобы не
+	// сжимать горячие заказы на каждый запрос. Копии увеличивают память кэша,
+	// поэтому значение можно переопределить переменной cachePrecompressEnv.
+	cachePrecompress    = true
+	cachePrecompressEnv = "L0_CACHE_PRECOMPRESS"
+
+	// apiKeysFile — JSON-массив API-ключей {"name", "role", "sha256"}; хранятся только хэши ключей.
+	// jwksFile — публичные ключи для проверки JWT. Отсутствующий файл означает, что
+	// этот способ входа отключён.
+	apiKeysFile = "./config/api_keys.json"
+	jwksFile    = "./config/jwks.json"
+	jwtIssuer   = ""
+	jwtAudience = "l0-orders"
+
+	// masterKeyFile — мастер-ключи для шифрования персональных данных в БД
+	// (создаётся командой go run ./cmd/rekey -init). Без файла данные хранятся открытым текстом.
+	masterKeyFile = "./config/master_keys.json"
+
+	// redactionHashKeyEnv — переменная окружения с ключом HMAC для правил hash в профилях маскирования.
+	redactionHashKeyEnv = "L0_REDACTION_HASH_KEY"
+
+	// traceExporterEnv выбирает экспортёр спанов OpenTelemetry: none, stdout или otlp
+	// (по умолчанию traceExporter). Для otlp спаны уходят в коллектор otlpEndpoint,
+	// адрес можно переопределить через OTEL_EXPORTER_OTLP_ENDPOINT.
+	traceExporterEnv = "L0_TRACE_EXPORTER"
+	traceExporter    = tracing.ExporterNone
+	otlpEndpoint     = "localhost:4317"
+	serviceName      = "l0-orders"
+
+	// logLevelEnv задаёт уровень логов: debug, info, warn или error (по умолчанию logLevel).
+	logLevelEnv = "L0_LOG_LEVEL"
+	logLevel    = "info"
+
+	// healthCheckTimeout ограничивает каждую проверку /readyz и /health.
+	// readinessDrainDelay — сколько /readyz отвечает 503 перед остановкой HTTP-сервера.
+	// warmRetryInterval — пауза между попытками прогрева кэша, пока БД недоступна.
+	healthCheckTimeout  = 2 * time.Second
+	readinessDrainDelay = 3 * time.Second
+	warmRetryInterval   = 5 * time.Second
 )
 
+// redactionProfiles — профили маскирования персональных данных. Профиль назначается
+// роли через roleProfiles или отдельному клиенту полем profile в API-ключе или JWT.
+var redactionProfiles = []dto.Profile{
+	// reader — внутренние дашборды: контакты и номер транзакции скрыты.
+	{Name: "reader", Rules: []dto.Rule{
+		{Field: "delivery.phone", Action: dto.ActionMask, Keep: 2},
+		{Field: "delivery.email", Action: dto.ActionMask},
+		{Field: "payment.transaction", Action: dto.ActionMask, Keep: 4},
+		{Field: "internal_signature", Action: dto.ActionDrop},
+	}},
+	// partner — внешние потребители: без контактов и адреса, покупатель — псевдоним.
+	{Name: "partner", Rules: []dto.Rule{
+		{Field: "delivery.name", Action: dto.ActionDrop},
+		{Field: "delivery.phone", Action: dto.ActionDrop},
+		{Field: "delivery.email", Action: dto.ActionDrop},
+		{Field: "delivery.address", Action: dto.ActionDrop},
+		{Field: "payment.transaction", Action: dto.ActionDrop},
+		{Field: "internal_signature", Action: dto.ActionDrop},
+		{Field: "customer_id", Action: dto.ActionHash},
+	}},
+}
+
+// roleProfiles — профиль по умолчанию для роли; support и admin видят заказ целиком.
+var roleProfiles = map[auth.Role]string{auth.RoleReader: "reader"}
+
+// rateLimits — лимиты запросов на клиента (API-ключ, токен или IP) для отдельных маршрутов:
+// Rate — запросов в секунду, Burst — размер корзины. Остальные маршруты получают defaultRateLimit.
+var rateLimits = map[string]ratelimit.Limit{
+	"GET /orders/{id}":           {Rate: 20, Burst: 40},
+	"GET /orders":                {Rate: 2, Burst: 5},
+	"POST /customers/{id}/erase": {Rate: 0.2, Burst: 2},
+	// Пробы оркестратора не ограничиваются.
+	"GET /healthz": {},
+	"GET /readyz":  {},
+	"GET /health":  {},
+}
+
+var defaultRateLimit = ratelimit.Limit{Rate: 10, Burst: 20}
+
 func main() {
+	level := os.Getenv(logLevelEnv)
+	if level == "" {
+		level = logLevel
+	}
+	if err := logging.Setup(level); err != nil {
+		logging.Fatal("logging", "err", err)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM) // Создаёт контекст, отменяемый по сигналам SIGINT и SIGTERM.
 	defer stop()                                                                           // Обеспечивает отмену уведомлений о сигналах при завершении main.
 
+	// Трассировка: при остановке накопленные спаны досылаются в экспортёр.
+	shutdownTracing, err := tracing.Setup(ctx, traceConfig())
+	if err != nil {
+		logging.Fatal("tracing", "err", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("tracing shutdown", "err", err)
+		}
+	}()
+
 	// Подключение к БД
-	database, err := db.New(dbURL) // Открывает пул соединений к базе
+	var dbOpts []db.Option
+	keys, err := fieldcrypt.LoadKeyring(masterKeyFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		slog.Warn("master keys not found, personal data is stored unencrypted", "file", masterKeyFile)
+	case err != nil:
+		logging.Fatal("load master keys", "err", err)
+	default:
+		dbOpts = append(dbOpts, db.WithKeyring(keys))
+	}
+	database, err := db.New(dbURL, dbOpts...) // Открывает пул соединений к базе
 	if err != nil {
-		log.Fatalf("db connect: %v", err)
+		logging.Fatal("db connect", "err", err)
 	}
 	defer database.Close()
 
 	if err := database.EnsureSchema(ctx); err != nil {
-		log.Fatalf("ensure schema: %v", err)
+		logging.Fatal("ensure schema", "err", err)
 	}
 
 	// Восстановление кэша из БД — прогрев оперативного хранилища.
-	c := cache.New()
-	orders := service.NewOrderService(database, c)
-	if warmed, err := orders.WarmCache(ctx); err != nil {
-		log.Printf("warm cache: %v", err)
-	} else {
-		log.Printf("cache warmed: %d orders", warmed)
+	precompress := cachePrecompress
+	if v := os.Getenv(cachePrecompressEnv); v != "" {
+		if precompress, err = strconv.ParseBool(v); err != nil {
+			logging.Fatal("parse "+cachePrecompressEnv, "err", err)
+		}
 	}
+	var cacheOpts []cache.Option
+	if precompress {
+		cacheOpts = append(cacheOpts, cache.WithPrecompression(compress.Supported...))
+	}
+	c := cache.New(cacheOpts...)
+	// Метрики пула соединений и размера кэша снимаются при каждом запросе /metrics.
+	prometheus.MustRegister(database.Collector(), c.SizeCollector())
+	orders := service.NewOrderService(database, c)
+	// Готовность: БД отвечает, кэш прогрет и подписка на NATS активна (см. ниже).
+	checker := health.New(healthCheckTimeout)
+	checker.Add("postgres", database.Ping)
+	var ready health.Flag
+	checker.Add("cache", ready.Check)
 
-	// Подписка на NATS — настройка обработки входящих сообщений.
-	sc, sub, err := nats.Subscribe(natsClusterID, natsClientID, natsChannel, func(msg *stan.Msg) {
-		orderID, err := orders.ProcessIncoming(ctx, msg.Data)
-		if err != nil {
-			log.Println("skip message:", err)
-			return
-		}
-		log.Println("saved order:", orderID)
-	})
+	lagMonitor := nats.NewLagMonitor(natsMonitorURL, natsLagThreshold)
+
+	authenticator, err := newAuthenticator()
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("auth", "err", err)
 	}
-	defer func() {
-		if err := sub.Close(); err != nil {
-			log.Printf("nats close subscription: %v", err)
-		}
-		sc.Close()
-	}()
 
-	mux := http.NewServeMux() // Создаёт мультиплексор HTTP-обработчиков.
-	// Обработчик отдаёт заказ из кэша, а при промахе подгружает из БД — описание поведения эндпоинта.
-	mux.HandleFunc("/orders/", func(w http.ResponseWriter, r *http.Request) { // Регистрирует обработчик запросов вида /orders/{id}.
-		id := r.URL.Path[len("/orders/"):] // Извлекает идентификатор заказа из пути URL.
-		if id == "" {
-			http.Error(w, "missing order id", http.StatusBadRequest)
-			return
-		}
-		data, err := orders.GetByID(r.Context(), id)
-		if err != nil {
-			log.Printf("get order %s: %v", id, err)
-			http.Error(w, "internal error", http.StatusInternalServerError)
-			return
-		}
-		if data == nil {
-			http.NotFound(w, r)
-			return
-		}
+	// Профили маскирования общие для HTTP и gRPC API.
+	profiles, err := service.NewProfiles(withHashKey(redactionProfiles, os.Getenv(redactionHashKeyEnv)), roleProfiles)
+	if err != nil {
+		logging.Fatal("redaction profiles", "err", err)
+	}
 
-		w.Header().Set("Content-Type", "application/json") // Настраивает заголовок ответа.
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+	// HTTP API и статический фронт.
+	handler, err := httpapi.New(orders, httpapi.Config{
+		Auth:              authenticator,
+		OrderCacheControl: orderCacheControl,
+		Profiles:          profiles,
+		RateLimits:        rateLimits,
+		DefaultRateLimit:  defaultRateLimit,
+		Health:            checker,
+		Lag:               lagMonitor,
+		Webhooks:          webhook.NewService(database),
+		StaticDir:         "./web/static",
 	})
-
-	// Отдаём статический фронт
-	mux.Handle("/", http.FileServer(http.Dir("./web/static"))) // Регистрирует файловый сервер для корневого маршрута.
+	if err != nil {
+		logging.Fatal("http api", "err", err)
+	}
 
 	srv := &http.Server{ // Конструирует HTTP-сервер с заданными параметрами.
 		Addr:         httpListenAddr,   // Адрес и порт прослушивания.
-		Handler:      mux,              // Мультиплексор, обрабатывающий входящие запросы.
+		Handler:      handler,          // Обработчик HTTP API.
 		ReadTimeout:  5 * time.Second,  // Лимит времени чтения запроса.
 		WriteTimeout: 10 * time.Second, // Лимит времени отправки ответа.
 		IdleTimeout:  60 * time.Second, // Таймаут бездействия для keep-alive соединений.
@@ -106,18 +288,278 @@ func main() {
 
 	// Сервис слушает HTTP в отдельной горутине, чтобы main мог ждать сигнала
 	go func() { // Запускает сервер в отдельной горутине.
-		log.Println("Server started on", httpListenAddr)
+		slog.Info("http server started", "addr", httpListenAddr)
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) { // Запускает слушатель и обрабатывает ошибки, игнорируя штатное закрытие.
-			log.Fatalf("http listen: %v", err)
+			logging.Fatal("http listen", "err", err)
+		}
+	}()
+
+	// HTTP уже слушает, поэтому пробы видят, что сервис жив, но ещё не готов.
+	// Снимок прошлого запуска позволяет отдавать заказы, пока идёт прогрев.
+	snapshot := os.Getenv(cacheSnapshotEnv)
+	if keys != nil && snapshot != "" {
+		slog.Warn("cache snapshot is disabled while field encryption is on", "env", cacheSnapshotEnv)
+		snapshot = ""
+	}
+	loadSnapshot(c, snapshot)
+	warmCache(ctx, orders)
+
+	// Подписка на NATS — настройка обработки входящих сообщений. Обработчик получает
+	// свой контекст: по сигналу остановки начатая транзакция доводится до конца.
+	consumer, err := nats.Subscribe(nats.Config{
+		URL:         natsURL,
+		ClusterID:   natsClusterID,
+		ClientID:    natsClientID,
+		Channel:     natsChannel,
+		DurableName: natsDurableName,
+		MaxInflight: natsMaxInflight,
+		AckWait:     natsAckWait,
+
+		PingInterval:     natsPingInterval,
+		PingMaxOut:       natsPingMaxOut,
+		ReconnectWait:    natsReconnectWait,
+		MaxReconnectWait: natsMaxReconnectWait,
+	}, func(ctx context.Context, msg *stan.Msg) error {
+		ctx, span := nats.StartSpan(ctx, natsChannel, msg)
+		ctx = logging.With(ctx, "nats_seq", msg.Sequence, "redelivered", msg.Redelivered)
+		res, err := orders.ProcessIncoming(ctx, msg.Data)
+		tracing.End(span, err)
+		if res.OrderUID != "" {
+			ctx = logging.With(ctx, "order_uid", res.OrderUID)
+		}
+		switch {
+		case errors.Is(err, service.ErrStorageUnavailable):
+			// Не подтверждаем: сервер повторит сообщение через natsAckWait.
+			slog.ErrorContext(ctx, "save order failed", "err", err)
+			return err
+		case err != nil:
+			// Повтор не поможет — подтверждаем, чтобы не получать сообщение снова.
+			slog.WarnContext(ctx, "skip message", "err", err)
+		default:
+			slog.InfoContext(ctx, "order saved")
+		}
+		return nil
+	})
+	if err != nil {
+		logging.Fatal("nats subscribe", "err", err)
+	}
+
+	checker.Add("nats", consumer.Check)
+	prometheus.MustRegister(consumer.Collector())
+	checker.Add("nats_lag", lagMonitor.Check)
+	go lagMonitor.Run(ctx, consumer, natsLagPollInterval)
+
+	// События из outbox публикуются через то же соединение, что и подписка.
+	eventsSubject := orderEventsSubject
+	if v := os.Getenv(orderEventsSubjectEnv); v != "" {
+		eventsSubject = v
+	}
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		outbox.New(database, consumer, eventsSubject, outboxInterval, outboxBatch, outboxRetention).Run(ctx)
+	}()
+	webhookDone := make(chan struct{})
+	go func() {
+		defer close(webhookDone)
+		webhook.NewDispatcher(database, webhook.Config{
+			Interval:    webhookInterval,
+			Batch:       webhookBatch,
+			Timeout:     webhookTimeout,
+			MaxAttempts: webhookMaxAttempts,
+			Backoff:     webhookBackoff,
+			MaxBackoff:  webhookMaxBackoff,
+			Retention:   webhookRetention,
+		}).Run(ctx)
+	}()
+	ready.Set()
+
+	// SIGHUP перечитывает кэш из БД: так подхватываются заказы, которые изменили
+	// в обход сервиса команды replay и reconcile.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go reloadCache(ctx, reload, orders)
+
+	// gRPC-сервер работает рядом с HTTP и использует тот же OrderService.
+	grpcOrders := grpcapi.NewServer(orders, profiles)
+	grpcSrv, grpcHealth := grpcapi.NewGRPCServer(grpcOrders, authenticator)
+	grpcLis, err := net.Listen("tcp", grpcListenAddr)
+	if err != nil {
+		logging.Fatal("grpc listen", "err", err)
+	}
+	go func() {
+		slog.Info("grpc server started", "addr", grpcListenAddr)
+		if err := grpcSrv.Serve(grpcLis); err != nil {
+			logging.Fatal("grpc serve", "err", err)
 		}
 	}()
 
 	<-ctx.Done()
-	log.Println("shutdown signal received") // Логирует получение сигнала остановки.
+	slog.Info("shutdown signal received") // Логирует получение сигнала остановки.
+
+	// Сначала /readyz начинает отвечать 503, и балансировщик успевает убрать сервис
+	// из ротации, пока HTTP-сервер ещё обслуживает запросы.
+	checker.Drain()
+	grpcHealth.Shutdown() // Переводит все сервисы в NOT_SERVING.
+	time.Sleep(readinessDrainDelay)
+
+	grpcOrders.Shutdown()   // Завершает потоки WatchOrders.
+	grpcSrv.GracefulStop()  // Дожидается текущих unary-вызовов.
+	orders.Events().Close() // Закрывает SSE/WebSocket-ленты, иначе srv.Shutdown будет их ждать.
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Создаёт контекст с таймаутом для аккуратного завершения сервера.
 	defer cancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Printf("http shutdown: %v", err)
+		slog.Error("http shutdown", "err", err)
+	}
+
+	// Новые сообщения больше не берутся, начатые доводятся до коммита и подтверждения;
+	// затем сохраняется снимок кэша, закрывается NATS, а БД — отложенным database.Close.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), natsDrainTimeout)
+	defer cancelDrain()
+	if err := consumer.Drain(drainCtx); err != nil {
+		slog.Error("nats drain", "err", err)
+	}
+	saveSnapshot(c, snapshot)
+	<-relayDone   // неотправленные события останутся в outbox до следующего запуска
+	<-webhookDone // прерванные доставки повторятся после следующего запуска
+	if err := consumer.Close(); err != nil {
+		slog.Error("nats close", "err", err)
+	}
+}
+
+// loadSnapshot загружает снимок кэша, если он есть; пустой path отключает снимки.
+func loadSnapshot(c *cache.Cache, path string) {
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Error("open cache snapshot", "err", err)
+		return
+	}
+	defer f.Close()
+	n, err := c.ReadSnapshot(f)
+	if err != nil {
+		slog.Error("read cache snapshot", "file", path, "err", err)
+		return
+	}
+	slog.Info("cache snapshot loaded", "file", path, "orders", n)
+}
+
+// saveSnapshot атомарно записывает снимок кэша: во временный файл, затем rename.
+// Файл доступен только владельцу — в нём персональные данные.
+func saveSnapshot(c *cache.Cache, path string) {
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		slog.Error("cache snapshot dir", "err", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cache_snapshot-*")
+	if err != nil {
+		slog.Error("create cache snapshot", "err", err)
+		return
+	}
+	defer os.Remove(tmp.Name()) // после rename файла под этим именем уже нет
+
+	n, err := 0, tmp.Chmod(0o600)
+	if err == nil {
+		n, err = c.WriteSnapshot(tmp)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		slog.Error("write cache snapshot", "file", path, "err", err)
+		return
+	}
+	slog.Info("cache snapshot saved", "file", path, "orders", n)
+}
+
+// warmCache загружает заказы из БД в кэш, повторяя попытки, пока БД недоступна.
+// До успешного прогрева сервис не считается готовым.
+func warmCache(ctx context.Context, orders *service.OrderService) {
+	for {
+		warmed, err := orders.WarmCache(ctx)
+		if err == nil {
+			slog.Info("cache warmed", "orders", warmed)
+			return
+		}
+		slog.Error("warm cache", "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(warmRetryInterval):
+		}
+	}
+}
+
+// reloadCache перечитывает кэш из БД по каждому сигналу из reload, пока ctx не отменён.
+// Сервис остаётся готовым: кэш заменяется целиком, когда чтение закончено.
+func reloadCache(ctx context.Context, reload <-chan os.Signal, orders *service.OrderService) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+		}
+		warmed, err := orders.WarmCache(ctx)
+		if err != nil {
+			slog.Error("reload cache", "err", err)
+			continue
+		}
+		slog.Info("cache reloaded", "orders", warmed)
+	}
+}
+
+// traceConfig собирает настройки трассировки из констант и переменных окружения.
+func traceConfig() tracing.Config {
+	cfg := tracing.Config{ServiceName: serviceName, Exporter: traceExporter, OTLPInsecure: true}
+	if v := os.Getenv(traceExporterEnv); v != "" {
+		cfg.Exporter = v
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		cfg.OTLPEndpoint = otlpEndpoint
+	}
+	return cfg
+}
+
+// newAuthenticator загружает API-ключи и JWKS из файлов конфигурации.
+func newAuthenticator() (*auth.Authenticator, error) {
+	keys, err := auth.LoadAPIKeys(apiKeysFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("auth: api keys file not found, api keys disabled", "file", apiKeysFile)
+	} else if err != nil {
+		return nil, err
+	}
+
+	jwks, err := auth.LoadJWKS(jwksFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("auth: jwks file not found, jwt disabled", "file", jwksFile)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return auth.New(keys, auth.JWTConfig{Keys: jwks, Issuer: jwtIssuer, Audience: jwtAudience})
+}
+
+// withHashKey подставляет ключ HMAC во все профили маскирования.
+func withHashKey(profiles []dto.Profile, key string) []dto.Profile {
+	if key == "" {
+		slog.Warn("redaction hash key is not set, hashed fields can be brute-forced", "env", redactionHashKeyEnv)
+	}
+	out := make([]dto.Profile, len(profiles))
+	for i, p := range profiles {
+		p.HashKey = []byte(key)
+		out[i] = p
 	}
+	return out
 }
@@ -0,0 +1,153 @@
+// Package auth проверяет учётные данные клиентов HTTP и gRPC API: статические API-ключи
+// (хранятся только их SHA-256) и JWT, подписанные ключами из локального JWKS.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Role — роль клиента. Роли упорядочены: admin может всё, что support, а support — всё, что reader.
+type Role string
+
+const (
+	RoleReader  Role = "reader"
+	RoleSupport Role = "support"
+	RoleAdmin   Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleReader: 1, RoleSupport: 2, RoleAdmin: 3}
+
+// Allows сообщает, достаточно ли роли r для операции, требующей required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] > 0 && roleRank[r] >= roleRank[required]
+}
+
+// ParseRole проверяет, что s — известная роль.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if roleRank[r] == 0 {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return r, nil
+}
+
+// APIKeyHeader — заголовок, в котором клиент передаёт API-ключ.
+const APIKeyHeader = "X-API-Key"
+
+var (
+	// ErrNoCredentials — в запросе нет ни API-ключа, ни bearer-токена.
+	ErrNoCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials — ключ неизвестен или токен не прошёл проверку.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal — аутентифицированный клиент.
+type Principal struct {
+	// Subject — имя API-ключа или claim sub из JWT; попадает в audit_log.
+	Subject string
+	Role    Role
+	// Profile — имя профиля маскирования персональных данных для этого клиента;
+	// пусто — профиль по умолчанию для роли.
+	Profile string
+}
+
+// APIKey — запись файла API-ключей. Сам ключ не хранится, только его SHA-256 в hex.
+type APIKey struct {
+	Name    string `json:"name"`
+	Role    Role   `json:"role"`
+	SHA256  string `json:"sha256"`
+	Profile string `json:"profile,omitempty"`
+}
+
+// HashKey возвращает SHA-256 ключа в том виде, в котором он записывается в файл.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadAPIKeys читает JSON-массив APIKey из файла.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// Authenticator определяет Principal по заголовкам запроса.
+type Authenticator struct {
+	keys map[string]Principal // SHA-256 ключа -> владелец
+	jwt  *jwtVerifier
+}
+
+// New собирает Authenticator из API-ключей и настроек JWT. Если jwtCfg.Keys пуст,
+// bearer-токены не принимаются.
+func New(keys []APIKey, jwtCfg JWTConfig) (*Authenticator, error) {
+	a := &Authenticator{keys: make(map[string]Principal, len(keys))}
+	for _, k := range keys {
+		if _, err := ParseRole(string(k.Role)); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		hash := strings.ToLower(k.SHA256)
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: sha256 must be 64 hex characters", k.Name)
+		}
+		a.keys[hash] = Principal{Subject: k.Name, Role: k.Role, Profile: k.Profile}
+	}
+	if len(jwtCfg.Keys) > 0 {
+		a.jwt = newJWTVerifier(jwtCfg)
+	}
+	return a, nil
+}
+
+// Authenticate проверяет заголовок X-API-Key или "Authorization: Bearer <jwt>".
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	return a.AuthenticateHeaders(r.Header.Get(APIKeyHeader), r.Header.Get("Authorization"))
+}
+
+// AuthenticateHeaders проверяет значения API-ключа и заголовка Authorization,
+// полученные не из HTTP-запроса (например, из метаданных gRPC). API-ключ важнее.
+func (a *Authenticator) AuthenticateHeaders(apiKey, authorization string) (Principal, error) {
+	if apiKey != "" {
+		// Сравниваются хэши, поэтому время поиска в map не раскрывает сам ключ.
+		p, ok := a.keys[HashKey(apiKey)]
+		if !ok {
+			return Principal{}, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+		}
+		return p, nil
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	if a.jwt == nil {
+		return Principal{}, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+	}
+	return a.jwt.verify(token)
+}
+
+type principalKey struct{}
+
+// WithPrincipal кладёт Principal в контекст запроса.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext возвращает Principal из контекста; для неаутентифицированного запроса — нулевой.
+func FromContext(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalKey{}).(Principal)
+	return p
+}
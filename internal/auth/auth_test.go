@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRoleAllows(t *testing.T) {
+	if !RoleAdmin.Allows(RoleReader) || !RoleSupport.Allows(RoleSupport) {
+		t.Fatal("higher role must include lower")
+	}
+	if RoleReader.Allows(RoleSupport) || Role("").Allows(RoleReader) || Role("root").Allows(RoleReader) {
+		t.Fatal("unexpected permission")
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	a, err := New([]APIKey{{Name: "dashboard", Role: RoleReader, SHA256: HashKey("secret")}}, JWTConfig{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	p, err := a.Authenticate(req)
+	if err != nil || p != (Principal{Subject: "dashboard", Role: RoleReader}) {
+		t.Fatalf("got %+v, %v", p, err)
+	}
+
+	req.Header.Set(APIKeyHeader, "wrong")
+	if _, err := a.Authenticate(req); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	if _, err := a.Authenticate(httptest.NewRequest("GET", "/", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestNewRejectsBadKeys(t *testing.T) {
+	if _, err := New([]APIKey{{Name: "x", Role: RoleReader, SHA256: "abc"}}, JWTConfig{}); err == nil {
+		t.Fatal("expected error for short hash")
+	}
+	if _, err := New([]APIKey{{Name: "x", Role: "root", SHA256: HashKey("k")}}, JWTConfig{}); err == nil {
+		t.Fatal("expected error for unknown role")
+	}
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa-1","use":"sig","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec-1","crv":"P-256","x":%q,"y":%q},
+		{"kty":"RSA","kid":"enc-1","use":"enc","n":"AQAB","e":"AQAB"}
+	]}`, b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()), b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))))
+
+	keys, err := ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatalf("parse jwks: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 signing keys, got %d", len(keys))
+	}
+	a, err := New(nil, JWTConfig{Keys: keys, Issuer: "idp", Audience: "l0"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key any, c jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(method, c)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return s
+	}
+	valid := func(role string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "alice", "role": role, "iss": "idp", "aud": "l0", "exp": time.Now().Add(time.Minute).Unix()}
+	}
+	expired := valid("admin")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	foreign, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	cases := []struct {
+		name  string
+		token string
+		want  Role
+	}{
+		{"rsa", sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, valid("support")), RoleSupport},
+		{"ec", sign(jwt.SigningMethodES256, "ec-1", ecKey, valid("admin")), RoleAdmin},
+		{"expired", sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, expired), ""},
+		{"unknown role", sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, valid("root")), ""},
+		{"foreign key", sign(jwt.SigningMethodRS256, "rsa-1", foreign, valid("admin")), ""},
+		{"hmac", sign(jwt.SigningMethodHS256, "rsa-1", []byte("secret"), valid("admin")), ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		p, err := a.Authenticate(req)
+		if tc.want == "" {
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("%s: expected ErrInvalidCredentials, got %+v, %v", tc.name, p, err)
+			}
+			continue
+		}
+		if err != nil || p.Role != tc.want || p.Subject != "alice" {
+			t.Fatalf("%s: got %+v, %v", tc.name, p, err)
+		}
+	}
+}
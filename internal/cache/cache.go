@@ -3,39 +3,196 @@ package cache
 import (
 	"encoding/json"
 	"sync"
+	"time"
+
+	"L0/internal/compress"
 )
 
+// Entry — закэшированное представление заказа
+type Entry struct {
+	Data    json.RawMessage // нормализованный JSON DTO
+	ETag    string          // сильный ETag, посчитанный по Data
+	ModTime time.Time       // время последнего изменения заказа в БД
+
+	Encoded map[string][]byte // заранее сжатые копии Data по Content-Encoding (если включено)
+}
+
+// item — заказ в кэше: полное представление и производные от него (например,
+// с замаскированными персональными данными) по имени варианта
+type item struct {
+	Entry
+	variants map[string]Entry
+}
+
 // Cache хранит JSON по ключу order_uid
 type Cache struct {
-	mu sync.RWMutex               // защищает map от одновременного доступа нескольких горутин
-	m  map[string]json.RawMessage // данные кэша
+	mu sync.RWMutex    // защищает map от одновременного доступа нескольких горутин
+	m  map[string]item // данные кэша
+
+	// touched — заказы, изменённые после BeginReplace: Replace оставляет их как есть,
+	// потому что данные для замены могли быть прочитаны раньше. nil — замена не идёт.
+	touched map[string]struct{}
+
+	encodings []string // кодировки, в которых записи сжимаются при Set
+}
+
+// Option настраивает кэш
+type Option func(*Cache)
+
+// WithPrecompression включает хранение сжатых копий каждой записи,
+// чтобы горячие заказы не сжимались заново на каждый запрос
+func WithPrecompression(encodings ...string) Option {
+	return func(c *Cache) {
+		c.encodings = encodings
+	}
 }
 
 // New создаёт пустой кэш
-func New() *Cache {
-	return &Cache{m: make(map[string]json.RawMessage)} // инициализация map
+func New(opts ...Option) *Cache {
+	c := &Cache{m: make(map[string]item)} // инициализация map
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// Get вытаскивает JSON по ключу
-func (c *Cache) Get(id string) (json.RawMessage, bool) {
+// Get вытаскивает запись по ключу
+func (c *Cache) Get(id string) (Entry, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	val, ok := c.m[id]
-	return val, ok // возвращаем JSON
+	observe(fullVariant, ok)
+	return val.Entry, ok // возвращаем запись
+}
+
+// Len возвращает число заказов в кэше
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.m)
+}
+
+// Set кладёт запись в кэш; варианты прежней версии заказа сбрасываются
+func (c *Cache) Set(id string, entry Entry) {
+	entry = c.precompress(entry) // сжимаем до захвата блокировки
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[id] = item{Entry: entry}
+	c.touch(id)
+}
+
+// GetVariant вытаскивает вариант заказа по имени, если он посчитан по версии
+// с ETag baseETag
+func (c *Cache) GetVariant(id, variant, baseETag string) (Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	it := c.m[id]
+	val, ok := it.variants[variant]
+	ok = ok && it.ETag == baseETag
+	observe(variant, ok)
+	return val, ok
+}
+
+// SetVariant кладёт вариант заказа, посчитанный по версии с ETag baseETag, и
+// возвращает его вместе со сжатыми копиями. Если заказ успели удалить или
+// заменить, вариант устарел и не сохраняется, но всё равно возвращается
+func (c *Cache) SetVariant(id, variant, baseETag string, entry Entry) Entry {
+	entry = c.precompress(entry)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it, ok := c.m[id]
+	if !ok || it.ETag != baseETag {
+		return entry
+	}
+	if it.variants == nil {
+		it.variants = make(map[string]Entry)
+	}
+	it.variants[variant] = entry
+	c.m[id] = it
+	return entry
 }
 
-// Set кладёт JSON в кэш
-func (c *Cache) Set(id string, data json.RawMessage) {
+// Delete убирает заказ из кэша
+func (c *Cache) Delete(id string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.m[id] = data
+	delete(c.m, id)
+	c.touch(id)
 }
 
 // LoadAll массово грузит данные
-func (c *Cache) LoadAll(data map[string]json.RawMessage) {
+func (c *Cache) LoadAll(data map[string]Entry) {
+	prepared := make(map[string]Entry, len(data))
+	for k, v := range data {
+		prepared[k] = c.precompress(v)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range prepared {
+		c.m[k] = item{Entry: v}
+		c.touch(k)
+	}
+}
+
+// BeginReplace начинает запоминать заказы, которые меняются или удаляются до
+// следующего Replace, — вызывается перед чтением данных для замены
+func (c *Cache) BeginReplace() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.touched = make(map[string]struct{})
+}
+
+// AbortReplace перестаёт запоминать изменённые заказы, если данные для замены
+// прочитать не удалось
+func (c *Cache) AbortReplace() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.touched = nil
+}
+
+// Replace заменяет всё содержимое кэша: заказы, которых нет в data, удаляются.
+// Заказы, изменённые или удалённые после BeginReplace, остаются в текущем виде:
+// data для них могла устареть
+func (c *Cache) Replace(data map[string]Entry) {
+	m := make(map[string]item, len(data))
 	for k, v := range data {
-		c.m[k] = v
+		m[k] = item{Entry: c.precompress(v)}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.touched {
+		if it, ok := c.m[id]; ok {
+			m[id] = it
+		} else {
+			delete(m, id)
+		}
+	}
+	c.m = m
+	c.touched = nil
+}
+
+// touch отмечает заказ изменённым во время замены; вызывается под c.mu
+func (c *Cache) touch(id string) {
+	if c.touched != nil {
+		c.touched[id] = struct{}{}
+	}
+}
+
+// precompress заполняет Encoded для включённых кодировок
+func (c *Cache) precompress(entry Entry) Entry {
+	if len(c.encodings) == 0 {
+		return entry
+	}
+	encoded := make(map[string][]byte, len(c.encodings))
+	for _, enc := range c.encodings {
+		data, err := compress.Encode(enc, entry.Data)
+		if err != nil {
+			continue // без сжатой копии ответ будет сжат на лету
+		}
+		encoded[enc] = data
 	}
+	entry.Encoded = encoded
+	return entry
 }
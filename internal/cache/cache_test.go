@@ -4,29 +4,38 @@ import (
 	"bytes"
 	"encoding/json"
 	"testing"
+	"time"
+
+	"L0/internal/compress"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestCacheSetGet(t *testing.T) {
 	c := New()
 	payload := json.RawMessage(`{"id":1}`)
+	modTime := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
 
-	c.Set("order-1", payload)
+	c.Set("order-1", Entry{Data: payload, ETag: `"abc"`, ModTime: modTime})
 
 	got, ok := c.Get("order-1")
 	if !ok {
 		t.Fatal("expected cache hit")
 	}
-	if !bytes.Equal(got, payload) {
-		t.Fatalf("unexpected payload: %s", string(got))
+	if !bytes.Equal(got.Data, payload) {
+		t.Fatalf("unexpected payload: %s", string(got.Data))
+	}
+	if got.ETag != `"abc"` || !got.ModTime.Equal(modTime) {
+		t.Fatalf("unexpected metadata: %s %s", got.ETag, got.ModTime)
 	}
 }
 
 func TestCacheLoadAll(t *testing.T) {
 	c := New()
-	bulk := map[string]json.RawMessage{
-		"one":   json.RawMessage(`{"val":1}`),
-		"two":   json.RawMessage(`{"val":2}`),
-		"three": json.RawMessage(`{"val":3}`),
+	bulk := map[string]Entry{
+		"one":   {Data: json.RawMessage(`{"val":1}`)},
+		"two":   {Data: json.RawMessage(`{"val":2}`)},
+		"three": {Data: json.RawMessage(`{"val":3}`)},
 	}
 
 	c.LoadAll(bulk)
@@ -36,8 +45,200 @@ func TestCacheLoadAll(t *testing.T) {
 		if !ok {
 			t.Fatalf("expected %s in cache", key)
 		}
-		if !bytes.Equal(got, expected) {
+		if !bytes.Equal(got.Data, expected.Data) {
 			t.Fatalf("unexpected value for %s", key)
 		}
 	}
 }
+
+func TestCacheDelete(t *testing.T) {
+	c := New()
+	c.Set("order-1", Entry{Data: json.RawMessage(`{"id":1}`)})
+
+	c.Delete("order-1")
+	c.Delete("missing")
+
+	if _, ok := c.Get("order-1"); ok {
+		t.Fatal("expected cache miss after delete")
+	}
+}
+
+func TestCachePrecompression(t *testing.T) {
+	c := New(WithPrecompression(compress.Gzip, compress.Zstd))
+	payload := json.RawMessage(`{"order_uid":"b563feb7b2b84b6test"}`)
+
+	c.Set("order-1", Entry{Data: payload})
+	c.LoadAll(map[string]Entry{"order-2": {Data: payload}})
+
+	for _, id := range []string{"order-1", "order-2"} {
+		got, _ := c.Get(id)
+		for _, enc := range []string{compress.Gzip, compress.Zstd} {
+			want, err := compress.Encode(enc, payload)
+			if err != nil {
+				t.Fatalf("encode %s: %v", enc, err)
+			}
+			if !bytes.Equal(got.Encoded[enc], want) {
+				t.Fatalf("%s: missing or wrong %s copy", id, enc)
+			}
+		}
+	}
+}
+
+func TestCacheWithoutPrecompression(t *testing.T) {
+	c := New()
+	c.Set("order-1", Entry{Data: json.RawMessage(`{"id":1}`)})
+
+	got, _ := c.Get("order-1")
+	if got.Encoded != nil {
+		t.Fatal("expected no compressed copies by default")
+	}
+}
+
+func TestCacheVariants(t *testing.T) {
+	c := New()
+	c.Set("order-1", Entry{Data: json.RawMessage(`{"phone":"+972"}`), ETag: `"v1"`})
+
+	missesBefore := testutil.ToFloat64(misses.WithLabelValues("reader"))
+	hitsBefore := testutil.ToFloat64(hits.WithLabelValues("reader"))
+	if _, ok := c.GetVariant("order-1", "reader", `"v1"`); ok {
+		t.Fatal("unexpected variant before SetVariant")
+	}
+	stored := c.SetVariant("order-1", "reader", `"v1"`, Entry{Data: json.RawMessage(`{"phone":"***"}`), ETag: `"v1-reader"`})
+	if stored.ETag != `"v1-reader"` {
+		t.Fatalf("SetVariant returned %+v", stored)
+	}
+	// Промах с последующим SetVariant учитывается только как промах.
+	if got := testutil.ToFloat64(misses.WithLabelValues("reader")) - missesBefore; got != 1 {
+		t.Fatalf("misses delta %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(hits.WithLabelValues("reader")) - hitsBefore; got != 0 {
+		t.Fatalf("hits delta %v, want 0", got)
+	}
+	got, ok := c.GetVariant("order-1", "reader", `"v1"`)
+	if !ok || got.ETag != `"v1-reader"` {
+		t.Fatalf("expected variant hit, got %+v %v", got, ok)
+	}
+	// Вариант не отдаётся тому, кто держит другую версию заказа.
+	if _, ok := c.GetVariant("order-1", "reader", `"v0"`); ok {
+		t.Fatal("variant of another version must not be returned")
+	}
+
+	// Вариант, посчитанный по устаревшей версии, не сохраняется.
+	c.SetVariant("order-1", "partner", `"v0"`, Entry{Data: json.RawMessage(`{}`)})
+	if _, ok := c.GetVariant("order-1", "partner", `"v0"`); ok {
+		t.Fatal("stale variant must not be stored")
+	}
+
+	// Новая версия заказа сбрасывает варианты.
+	c.Set("order-1", Entry{Data: json.RawMessage(`{"phone":"+973"}`), ETag: `"v2"`})
+	if _, ok := c.GetVariant("order-1", "reader", `"v2"`); ok {
+		t.Fatal("variants must be dropped on Set")
+	}
+
+	c.SetVariant("order-2", "reader", `"v1"`, Entry{})
+	if _, ok := c.GetVariant("order-2", "reader", `"v1"`); ok {
+		t.Fatal("variant of a missing order must not be stored")
+	}
+}
+
+func TestCacheMetrics(t *testing.T) {
+	c := New()
+	hitsBefore := testutil.ToFloat64(hits.WithLabelValues(fullVariant))
+	missesBefore := testutil.ToFloat64(misses.WithLabelValues(fullVariant))
+
+	c.Set("order-1", Entry{Data: json.RawMessage(`{}`), ETag: `"a"`})
+	c.Get("order-1")
+	c.Get("order-2")
+
+	if got := testutil.ToFloat64(hits.WithLabelValues(fullVariant)) - hitsBefore; got != 1 {
+		t.Fatalf("expected 1 hit, got %v", got)
+	}
+	if got := testutil.ToFloat64(misses.WithLabelValues(fullVariant)) - missesBefore; got != 1 {
+		t.Fatalf("expected 1 miss, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.SizeCollector()); got != 1 {
+		t.Fatalf("expected size 1, got %v", got)
+	}
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	modTime := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	c := New()
+	c.Set("order-1", Entry{Data: json.RawMessage(`{"id":1}`), ETag: `"a"`, ModTime: modTime})
+	c.SetVariant("order-1", "reader", `"a"`, Entry{Data: json.RawMessage(`{}`), ETag: `"a-reader"`})
+	c.Set("order-2", Entry{Data: json.RawMessage(`{"id":2}`), ETag: `"b"`, ModTime: modTime})
+
+	var buf bytes.Buffer
+	if n, err := c.WriteSnapshot(&buf); err != nil || n != 2 {
+		t.Fatalf("write snapshot: %d, %v", n, err)
+	}
+
+	restored := New(WithPrecompression(compress.Gzip))
+	if n, err := restored.ReadSnapshot(&buf); err != nil || n != 2 {
+		t.Fatalf("read snapshot: %d, %v", n, err)
+	}
+	got, ok := restored.Get("order-1")
+	if !ok || string(got.Data) != `{"id":1}` || got.ETag != `"a"` || !got.ModTime.Equal(modTime) || got.Encoded[compress.Gzip] == nil {
+		t.Fatalf("unexpected restored entry: %+v", got)
+	}
+	if _, ok := restored.GetVariant("order-1", "reader", `"a"`); ok {
+		t.Fatal("variants must not be restored from snapshot")
+	}
+
+	restored.Replace(map[string]Entry{"order-2": {Data: json.RawMessage(`{"id":2}`), ETag: `"c"`}})
+	if _, ok := restored.Get("order-1"); ok {
+		t.Fatal("Replace must drop orders missing from the new data")
+	}
+	if got, _ := restored.Get("order-2"); got.ETag != `"c"` {
+		t.Fatalf("Replace did not update order-2: %+v", got)
+	}
+}
+
+func TestReplaceKeepsChangesMadeDuringWarmup(t *testing.T) {
+	c := New()
+	c.Set("deleted", Entry{ETag: `"old"`})
+	c.Set("updated", Entry{ETag: `"old"`})
+
+	c.BeginReplace()
+	// Пока читается БД, один заказ удалили, другой обновили.
+	c.Delete("deleted")
+	c.Set("updated", Entry{ETag: `"new"`})
+	c.Replace(map[string]Entry{
+		"deleted": {ETag: `"old"`},
+		"updated": {ETag: `"old"`},
+		"other":   {ETag: `"x"`},
+	})
+
+	if _, ok := c.Get("deleted"); ok {
+		t.Fatal("Replace resurrected an order deleted during warm-up")
+	}
+	if got, _ := c.Get("updated"); got.ETag != `"new"` {
+		t.Fatalf("Replace overwrote a newer version: %+v", got)
+	}
+	if _, ok := c.Get("other"); !ok {
+		t.Fatal("Replace dropped an untouched order")
+	}
+
+	// Следующая замена без BeginReplace применяется целиком.
+	c.Replace(map[string]Entry{"deleted": {ETag: `"old"`}})
+	if _, ok := c.Get("deleted"); !ok || c.Len() != 1 {
+		t.Fatal("Replace without BeginReplace must apply data as is")
+	}
+}
+
+func TestAbortReplaceStopsTracking(t *testing.T) {
+	c := New()
+	c.BeginReplace()
+	c.Set("order-1", Entry{ETag: `"a"`})
+	c.AbortReplace() // прогрев не смог прочитать БД
+
+	c.Delete("order-1")
+	if c.touched != nil {
+		t.Fatalf("changes are still tracked after AbortReplace: %v", c.touched)
+	}
+	// Следующая замена применяется целиком, без заказов из прерванного прогрева.
+	c.Replace(map[string]Entry{"order-1": {ETag: `"b"`}})
+	if got, ok := c.Get("order-1"); !ok || got.ETag != `"b"` {
+		t.Fatalf("Replace after AbortReplace: %+v %v", got, ok)
+	}
+}
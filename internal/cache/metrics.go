@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// fullVariant — значение метки variant для полного представления заказа
+const fullVariant = "full"
+
+// hits и misses считают обращения к кэшу по варианту представления
+var (
+	hits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "l0_cache_hits_total",
+		Help: "Cache lookups that found an entry, by variant (full or redaction profile).",
+	}, []string{"variant"})
+	misses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "l0_cache_misses_total",
+		Help: "Cache lookups that found nothing, by variant (full or redaction profile).",
+	}, []string{"variant"})
+)
+
+// observe учитывает результат обращения к кэшу
+func observe(variant string, ok bool) {
+	if ok {
+		hits.WithLabelValues(variant).Inc()
+	} else {
+		misses.WithLabelValues(variant).Inc()
+	}
+}
+
+// SizeCollector отдаёт число заказов в кэше как метрику l0_cache_orders
+func (c *Cache) SizeCollector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "l0_cache_orders",
+		Help: "Number of orders held in the in-memory cache.",
+	}, func() float64 { return float64(c.Len()) })
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// snapshotRecord — строка снимка кэша в формате JSON Lines
+type snapshotRecord struct {
+	OrderUID string          `json:"order_uid"`
+	Data     json.RawMessage `json:"data"`
+	ETag     string          `json:"etag"`
+	ModTime  time.Time       `json:"mod_time"`
+}
+
+// WriteSnapshot пишет полные представления заказов в w; варианты профилей и
+// сжатые копии не сохраняются, они считаются заново
+func (c *Cache) WriteSnapshot(w io.Writer) (int, error) {
+	c.mu.RLock()
+	records := make([]snapshotRecord, 0, len(c.m))
+	for id, it := range c.m {
+		records = append(records, snapshotRecord{OrderUID: id, Data: it.Data, ETag: it.ETag, ModTime: it.ModTime})
+	}
+	c.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), bw.Flush()
+}
+
+// ReadSnapshot добавляет в кэш заказы из снимка, записанного WriteSnapshot
+func (c *Cache) ReadSnapshot(r io.Reader) (int, error) {
+	data := make(map[string]Entry)
+	dec := json.NewDecoder(r)
+	for {
+		var rec snapshotRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		data[rec.OrderUID] = Entry{Data: rec.Data, ETag: rec.ETag, ModTime: rec.ModTime}
+	}
+	c.LoadAll(data)
+	return len(data), nil
+}
@@ -0,0 +1,49 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Поддерживаемые значения Content-Encoding.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Supported — поддерживаемые кодировки в порядке предпочтения сервера.
+var Supported = []string{Zstd, Gzip}
+
+// zstdEncoder потокобезопасен для EncodeAll, поэтому создаётся один раз; ошибка
+// создания возвращается из каждого Encode, а не превращается в nil-кодировщик.
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+})
+
+// Encode сжимает data указанной кодировкой.
+func Encode(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, fmt.Errorf("zstd encoder: %w", err)
+		}
+		return enc.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
@@ -0,0 +1,60 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var payload = bytes.Repeat([]byte(`{"order_uid":"b563feb7b2b84b6test","track_number":"WBILMTESTTRACK"}`), 20)
+
+func TestEncodeGzipRoundTrip(t *testing.T) {
+	encoded, err := Encode(Gzip, payload)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gzip read: %v", err)
+	}
+	if !bytes.Equal(decoded, payload) {
+		t.Fatal("gzip round trip mismatch")
+	}
+}
+
+func TestEncodeZstdRoundTrip(t *testing.T) {
+	encoded, err := Encode(Zstd, payload)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if len(encoded) >= len(payload) {
+		t.Fatalf("expected compression, got %d >= %d", len(encoded), len(payload))
+	}
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatalf("zstd reader: %v", err)
+	}
+	defer dec.Close()
+	decoded, err := dec.DecodeAll(encoded, nil)
+	if err != nil {
+		t.Fatalf("zstd decode: %v", err)
+	}
+	if !bytes.Equal(decoded, payload) {
+		t.Fatal("zstd round trip mismatch")
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	if _, err := Encode("br", payload); err == nil {
+		t.Fatal("expected error for unsupported encoding")
+	}
+}
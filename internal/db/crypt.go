@@ -0,0 +1,355 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"L0/internal/fieldcrypt"
+	"L0/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// piiFields — персональные данные доставки, которые шифруются и в deliveries, и внутри raw.
+var piiFields = []string{"name", "phone", "email", "address"}
+
+// ErrNoKeyring — заказ зашифрован, а сервис запущен без файла ключей.
+var ErrNoKeyring = errors.New("order is encrypted but no keyring is configured")
+
+// sealing — данные шифрования заказа для записи в БД. Нулевое значение —
+// заказ хранится открытым текстом.
+type sealing struct {
+	dek      []byte  // DEK, зашифрованный мастер-ключом
+	keyID    *string // идентификатор мастер-ключа
+	emailIdx *string // слепые индексы для поиска
+	phoneIdx *string
+}
+
+// deliveryPII возвращает указатели на персональные поля доставки в порядке piiFields.
+func deliveryPII(d *model.Delivery) []*string {
+	return []*string{&d.Name, &d.Phone, &d.Email, &d.Address}
+}
+
+func fieldAAD(orderUID, field string) string {
+	return orderUID + "/delivery." + field
+}
+
+// seal шифрует персональные данные заказа новым DEK. Без файла ключей заказ
+// возвращается без изменений.
+func (db *DB) seal(order model.Order, raw json.RawMessage) (model.Order, json.RawMessage, sealing, error) {
+	if db.keys == nil {
+		return order, raw, sealing{}, nil
+	}
+
+	dek, wrapped, keyID, err := db.keys.NewDataKey()
+	if err != nil {
+		return model.Order{}, nil, sealing{}, err
+	}
+	s := sealing{
+		dek:      wrapped,
+		keyID:    &keyID,
+		emailIdx: nullable(db.keys.BlindIndex(fieldcrypt.IndexEmail, order.Delivery.Email)),
+		phoneIdx: nullable(db.keys.BlindIndex(fieldcrypt.IndexPhone, order.Delivery.Phone)),
+	}
+
+	for i, v := range deliveryPII(&order.Delivery) {
+		if *v, err = fieldcrypt.Seal(dek, *v, fieldAAD(order.OrderUID, piiFields[i])); err != nil {
+			return model.Order{}, nil, sealing{}, err
+		}
+	}
+	raw, err = transformRaw(raw, func(field, value string) (string, error) {
+		return fieldcrypt.Seal(dek, value, fieldAAD(order.OrderUID, field))
+	})
+	if err != nil {
+		return model.Order{}, nil, sealing{}, err
+	}
+	return order, raw, s, nil
+}
+
+// open расшифровывает raw заказа, прочитанного из БД. wrapped == nil — заказ не зашифрован.
+func (db *DB) open(orderUID string, raw json.RawMessage, wrapped []byte, keyID *string) (json.RawMessage, error) {
+	if wrapped == nil {
+		return raw, nil
+	}
+	dek, err := db.dataKey(wrapped, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return transformRaw(raw, func(field, value string) (string, error) {
+		return fieldcrypt.Open(dek, value, fieldAAD(orderUID, field))
+	})
+}
+
+// openDelivery расшифровывает персональные поля строки deliveries. wrapped == nil — заказ не зашифрован.
+func (db *DB) openDelivery(orderUID string, d *model.Delivery, wrapped []byte, keyID *string) error {
+	if wrapped == nil {
+		return nil
+	}
+	dek, err := db.dataKey(wrapped, keyID)
+	if err != nil {
+		return err
+	}
+	for i, v := range deliveryPII(d) {
+		if *v, err = fieldcrypt.Open(dek, *v, fieldAAD(orderUID, piiFields[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataKey расшифровывает DEK заказа мастер-ключом keyID.
+func (db *DB) dataKey(wrapped []byte, keyID *string) ([]byte, error) {
+	if db.keys == nil || keyID == nil {
+		return nil, ErrNoKeyring
+	}
+	return db.keys.UnwrapDataKey(*keyID, wrapped)
+}
+
+// secretAAD привязывает шифротекст секрета webhook к URL подписки.
+func secretAAD(url string) string {
+	return "webhook/" + url
+}
+
+// sealSecret шифрует секрет подписи webhook собственным DEK. Без файла ключей
+// секрет возвращается как есть, а wrapped и keyID — nil.
+func (db *DB) sealSecret(url, secret string) (sealed string, wrapped []byte, keyID *string, err error) {
+	if db.keys == nil {
+		return secret, nil, nil, nil
+	}
+	dek, wrapped, id, err := db.keys.NewDataKey()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if sealed, err = fieldcrypt.Seal(dek, secret, secretAAD(url)); err != nil {
+		return "", nil, nil, err
+	}
+	return sealed, wrapped, &id, nil
+}
+
+// openSecret расшифровывает секрет webhook. wrapped == nil — секрет хранится открытым текстом.
+func (db *DB) openSecret(url, secret string, wrapped []byte, keyID *string) (string, error) {
+	if wrapped == nil {
+		return secret, nil
+	}
+	dek, err := db.dataKey(wrapped, keyID)
+	if err != nil {
+		return "", err
+	}
+	return fieldcrypt.Open(dek, secret, secretAAD(url))
+}
+
+// transformRaw применяет fn к строковым персональным полям raw.delivery.
+// Остальной JSON не разбирается, чтобы не потерять точность чисел.
+func transformRaw(raw json.RawMessage, fn func(field, value string) (string, error)) (json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	var delivery map[string]json.RawMessage
+	if err := json.Unmarshal(doc["delivery"], &delivery); err != nil || delivery == nil {
+		return raw, nil // без объекта delivery шифровать нечего
+	}
+
+	for _, field := range piiFields {
+		var value string
+		if err := json.Unmarshal(delivery[field], &value); err != nil {
+			continue // поля нет или оно не строка
+		}
+		out, err := fn(field, value)
+		if err != nil {
+			return nil, err
+		}
+		if delivery[field], err = json.Marshal(out); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if doc["delivery"], err = json.Marshal(delivery); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// FindOrderIDs ищет заказы по точному совпадению email и/или телефона доставки.
+// При включённом шифровании поиск идёт по слепым индексам, иначе — по открытым колонкам
+// с той же нормализацией (регистр email, только цифры телефона).
+func (db *DB) FindOrderIDs(ctx context.Context, email, phone string) ([]string, error) {
+	query := `SELECT order_uid FROM deliveries
+		WHERE ($1 = '' OR lower(trim(email)) = lower(trim($1)))
+		  AND ($2 = '' OR regexp_replace(phone, '\D', '', 'g') = regexp_replace($2, '\D', '', 'g'))
+		ORDER BY order_uid`
+	if db.keys != nil {
+		email = db.keys.BlindIndex(fieldcrypt.IndexEmail, email)
+		phone = db.keys.BlindIndex(fieldcrypt.IndexPhone, phone)
+		query = `SELECT order_uid FROM deliveries
+			WHERE ($1 = '' OR email_bidx = $1) AND ($2 = '' OR phone_bidx = $2)
+			ORDER BY order_uid`
+	}
+	if email == "" && phone == "" {
+		return nil, nil
+	}
+	rows, err := db.pool.Query(ctx, query, email, phone)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// RewrapKeys перешифровывает активным мастер-ключом до batch ключей данных заказов
+// и до batch ключей секретов webhook, зашифрованных другими мастер-ключами. Сами
+// заказы и секреты не перечитываются. Возвращает число обработанных ключей;
+// 0 — перешифровывать больше нечего.
+func (db *DB) RewrapKeys(ctx context.Context, batch int) (int, error) {
+	if db.keys == nil {
+		return 0, ErrNoKeyring
+	}
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT order_uid, dek, dek_key_id FROM orders
+		WHERE dek IS NOT NULL AND dek_key_id <> $1
+		LIMIT $2 FOR UPDATE SKIP LOCKED`,
+		db.keys.ActiveKeyID(),
+		batch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type wrappedKey struct {
+		uid   string
+		dek   []byte
+		keyID string
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (wrappedKey, error) {
+		var k wrappedKey
+		err := row.Scan(&k.uid, &k.dek, &k.keyID)
+		return k, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, k := range keys {
+		dek, keyID, err := db.keys.Rewrap(k.keyID, k.dek)
+		if err != nil {
+			return 0, fmt.Errorf("rewrap %s: %w", k.uid, err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE orders SET dek = $2, dek_key_id = $3 WHERE order_uid = $1`, k.uid, dek, keyID); err != nil {
+			return 0, err
+		}
+	}
+
+	rows, err = tx.Query(ctx,
+		`SELECT id, secret_dek, secret_key_id FROM webhooks
+		WHERE secret_dek IS NOT NULL AND secret_key_id <> $1
+		LIMIT $2 FOR UPDATE SKIP LOCKED`,
+		db.keys.ActiveKeyID(),
+		batch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type webhookKey struct {
+		id    int64
+		dek   []byte
+		keyID string
+	}
+	hooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (webhookKey, error) {
+		var k webhookKey
+		err := row.Scan(&k.id, &k.dek, &k.keyID)
+		return k, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, k := range hooks {
+		dek, keyID, err := db.keys.Rewrap(k.keyID, k.dek)
+		if err != nil {
+			return 0, fmt.Errorf("rewrap webhook %d: %w", k.id, err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE webhooks SET secret_dek = $2, secret_key_id = $3 WHERE id = $1`, k.id, dek, keyID); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys) + len(hooks), tx.Commit(ctx)
+}
+
+// EncryptPlaintext шифрует до batch заказов и до batch секретов webhook, сохранённых
+// до включения шифрования. Содержимое заказов не меняется, поэтому updated_at
+// сохраняется прежним и у клиентов не устаревают Last-Modified и кэшированные ответы.
+// Возвращает число обработанных записей; 0 — открытых не осталось.
+func (db *DB) EncryptPlaintext(ctx context.Context, batch int) (int, error) {
+	if db.keys == nil {
+		return 0, ErrNoKeyring
+	}
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT raw, updated_at FROM orders WHERE dek IS NULL LIMIT $1 FOR UPDATE SKIP LOCKED`, batch)
+	if err != nil {
+		return 0, err
+	}
+	type plainOrder struct {
+		Raw       json.RawMessage
+		UpdatedAt time.Time
+	}
+	plain, err := pgx.CollectRows(rows, pgx.RowToStructByPos[plainOrder])
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range plain {
+		var order model.Order
+		if err := json.Unmarshal(p.Raw, &order); err != nil {
+			return 0, err
+		}
+		if _, _, err := db.saveOrder(ctx, tx, order, p.Raw); err != nil {
+			return 0, fmt.Errorf("encrypt %s: %w", order.OrderUID, err)
+		}
+		// saveOrder ставит updated_at = now(), а заказ по существу не изменился.
+		if _, err := tx.Exec(ctx, `UPDATE orders SET updated_at = $2 WHERE order_uid = $1`, order.OrderUID, p.UpdatedAt); err != nil {
+			return 0, err
+		}
+	}
+
+	rows, err = tx.Query(ctx, `SELECT id, url, secret FROM webhooks WHERE secret_dek IS NULL LIMIT $1 FOR UPDATE SKIP LOCKED`, batch)
+	if err != nil {
+		return 0, err
+	}
+	hooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Webhook, error) {
+		var w Webhook
+		err := row.Scan(&w.ID, &w.URL, &w.Secret)
+		return w, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, w := range hooks {
+		secret, dek, keyID, err := db.sealSecret(w.URL, w.Secret)
+		if err != nil {
+			return 0, fmt.Errorf("encrypt webhook %d: %w", w.ID, err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE webhooks SET secret = $2, secret_dek = $3, secret_key_id = $4 WHERE id = $1`, w.ID, secret, dek, keyID); err != nil {
+			return 0, err
+		}
+	}
+	return len(plain) + len(hooks), tx.Commit(ctx)
+}
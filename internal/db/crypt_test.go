@@ -0,0 +1,130 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"L0/internal/fieldcrypt"
+	"L0/internal/model"
+)
+
+const plainOrder = `{"order_uid":"order-1","delivery":{"name":"Test Testov","phone":"+9720000000","city":"Kiryat Mozkin","email":"test@gmail.com","address":"Ploshad Mira 15"},"payment":{"amount":1817,"payment_dt":1637907727}}`
+
+func TestSealOpenOrder(t *testing.T) {
+	keys, err := fieldcrypt.NewKeyring("k1")
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	db := &DB{keys: keys}
+
+	var order model.Order
+	json.Unmarshal([]byte(plainOrder), &order)
+	sealedOrder, raw, s, err := db.seal(order, json.RawMessage(plainOrder))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	for _, pii := range []string{"Test Testov", "+9720000000", "test@gmail.com", "Ploshad Mira"} {
+		if strings.Contains(string(raw), pii) {
+			t.Fatalf("raw still contains %q: %s", pii, raw)
+		}
+	}
+	if sealedOrder.Delivery.Phone == order.Delivery.Phone || sealedOrder.Delivery.City != order.Delivery.City {
+		t.Fatalf("unexpected sealed delivery: %+v", sealedOrder.Delivery)
+	}
+	if s.dek == nil || *s.keyID != "k1" || *s.emailIdx != keys.BlindIndex(fieldcrypt.IndexEmail, "TEST@gmail.com") {
+		t.Fatalf("unexpected sealing: %+v", s)
+	}
+
+	opened, err := db.open("order-1", raw, s.dek, s.keyID)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	var got, want any
+	json.Unmarshal(opened, &got)
+	json.Unmarshal([]byte(plainOrder), &want)
+	a, _ := json.Marshal(got)
+	b, _ := json.Marshal(want)
+	if string(a) != string(b) {
+		t.Fatalf("round trip mismatch:\n%s\n%s", a, b)
+	}
+
+	// Шифротекст другого заказа не расшифровывается ключом и AAD этого.
+	if _, err := db.open("order-2", raw, s.dek, s.keyID); err == nil {
+		t.Fatal("expected error for foreign order_uid")
+	}
+	if _, err := (&DB{}).open("order-1", raw, s.dek, s.keyID); !errors.Is(err, ErrNoKeyring) {
+		t.Fatalf("expected ErrNoKeyring, got %v", err)
+	}
+}
+
+func TestSealWithoutKeyringIsNoop(t *testing.T) {
+	db := &DB{}
+	_, raw, s, err := db.seal(model.Order{OrderUID: "order-1"}, json.RawMessage(plainOrder))
+	if err != nil || string(raw) != plainOrder || s.dek != nil {
+		t.Fatalf("expected plaintext passthrough, got %s %+v %v", raw, s, err)
+	}
+	opened, err := db.open("order-1", raw, nil, nil)
+	if err != nil || string(opened) != plainOrder {
+		t.Fatalf("expected plaintext read, got %s %v", opened, err)
+	}
+}
+
+func TestSealOpenWebhookSecret(t *testing.T) {
+	keys, err := fieldcrypt.NewKeyring("k1")
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	db := &DB{keys: keys}
+
+	const url, secret = "https://partner.example/hooks", "whsec_test"
+	sealed, dek, keyID, err := db.sealSecret(url, secret)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if strings.Contains(sealed, secret) || dek == nil || *keyID != "k1" {
+		t.Fatalf("secret not sealed: %q %v", sealed, keyID)
+	}
+	if got, err := db.openSecret(url, sealed, dek, keyID); err != nil || got != secret {
+		t.Fatalf("open: %q %v", got, err)
+	}
+	// Шифротекст привязан к URL подписки.
+	if _, err := db.openSecret("https://evil.example/", sealed, dek, keyID); err == nil {
+		t.Fatal("expected error for foreign url")
+	}
+
+	plain, dek, keyID, err := (&DB{}).sealSecret(url, secret)
+	if err != nil || plain != secret || dek != nil || keyID != nil {
+		t.Fatalf("expected plaintext passthrough, got %q %v", plain, err)
+	}
+}
+
+func TestOpenClaimedSkipsBrokenSecrets(t *testing.T) {
+	keys, err := fieldcrypt.NewKeyring("k1")
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	db := &DB{keys: keys}
+
+	const url, secret = "https://partner.example/hooks", "whsec_test"
+	sealed, dek, keyID, err := db.sealSecret(url, secret)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	claimed := []claimedDelivery{
+		{DueDelivery: DueDelivery{ID: 1, URL: url, Secret: sealed}, dek: dek, keyID: keyID},
+		// Секрет привязан к другому URL и не расшифруется.
+		{DueDelivery: DueDelivery{ID: 2, URL: "https://evil.example/", Secret: sealed}, dek: dek, keyID: keyID},
+		{DueDelivery: DueDelivery{ID: 3, URL: url, Secret: "plain"}, summary: []byte(`{"locale":"en"}`)},
+	}
+
+	due, broken := db.openClaimed(claimed)
+	if len(due) != 2 || due[0].ID != 1 || due[0].Secret != secret || due[1].ID != 3 || due[1].Event.Order == nil {
+		t.Fatalf("unexpected due deliveries: %+v", due)
+	}
+	if len(broken) != 1 || broken[2] == nil {
+		t.Fatalf("expected delivery 2 to be broken, got %v", broken)
+	}
+}
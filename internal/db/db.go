@@ -4,49 +4,154 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
+	"L0/internal/fieldcrypt"
 	"L0/internal/model"
+	"L0/internal/tracing"
 	"L0/migrations"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type DB struct {
 	pool *pgxpool.Pool
+	keys *fieldcrypt.Keyring // nil — персональные данные хранятся открытым текстом
 }
 
-func New(conn string) (*DB, error) {
+// Option настраивает DB.
+type Option func(*DB)
+
+// WithKeyring включает шифрование персональных данных заказов (см. crypt.go).
+func WithKeyring(keys *fieldcrypt.Keyring) Option {
+	return func(db *DB) { db.keys = keys }
+}
+
+// StoredOrder — исходный JSON заказа и время его последнего изменения.
+type StoredOrder struct {
+	Raw       json.RawMessage
+	UpdatedAt time.Time
+}
+
+func New(conn string, opts ...Option) (*DB, error) {
 	pool, err := pgxpool.New(context.Background(), conn)
 	if err != nil {
 		return nil, err
 	}
-	return &DB{pool: pool}, nil
+	db := &DB{pool: pool}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db, nil
 }
 
 func (db *DB) Close() {
 	db.pool.Close()
 }
 
+// Ping проверяет, что пул может получить соединение и БД отвечает.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.pool.Ping(ctx)
+}
+
 func (db *DB) EnsureSchema(ctx context.Context) error {
 	_, err := db.pool.Exec(ctx, migrations.CreateTables)
 	return err
 }
 
-func (db *DB) SaveOrder(ctx context.Context, order model.Order, raw json.RawMessage) error {
-	dateCreated, err := time.Parse(time.RFC3339, order.DateCreated)
+// SaveOrder сохраняет заказ и возвращает время изменения, записанное в БД, и
+// true, если заказа ещё не было. В той же транзакции в order_events пишется
+// событие created или updated.
+func (db *DB) SaveOrder(ctx context.Context, order model.Order, raw json.RawMessage) (_ time.Time, created bool, err error) {
+	defer observeSave(time.Now(), &err)
+	ctx, span := tracer.Start(ctx, "SaveOrder", trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := db.pool.Begin(ctx)
 	if err != nil {
-		dateCreated = time.Now().UTC()
+		return time.Time{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	updatedAt, created, err := db.saveOrder(ctx, tx, order, raw)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	eventType := EventUpdated
+	if created {
+		eventType = EventCreated
 	}
+	if err := writeEvent(ctx, tx, eventType, order.OrderUID, summarize(order, updatedAt)); err != nil {
+		return time.Time{}, false, err
+	}
+	return updatedAt, created, commit(ctx, tx)
+}
 
+// commit фиксирует транзакцию в отдельном спане.
+func commit(ctx context.Context, tx pgx.Tx) (err error) {
+	ctx, span := startSpan(ctx, "COMMIT", "")
+	defer func() { tracing.End(span, err) }()
+	return tx.Commit(ctx)
+}
+
+// UpdateOrder блокирует строку заказа, передаёт текущий raw в update и сохраняет
+// результат тем же путём, что и SaveOrder, в одной транзакции с событием updated.
+// Возвращает новое время изменения и false, если заказа нет.
+func (db *DB) UpdateOrder(ctx context.Context, orderUID string, update func(current json.RawMessage) (model.Order, json.RawMessage, error)) (time.Time, bool, error) {
 	tx, err := db.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return time.Time{}, false, err
 	}
 	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(ctx,
+	var current json.RawMessage
+	var dek []byte
+	var keyID *string
+	err = tx.QueryRow(ctx, `SELECT raw, dek, dek_key_id FROM orders WHERE order_uid = $1 FOR UPDATE`, orderUID).Scan(&current, &dek, &keyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if current, err = db.open(orderUID, current, dek, keyID); err != nil {
+		return time.Time{}, false, err
+	}
+
+	order, raw, err := update(current)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	updatedAt, _, err := db.saveOrder(ctx, tx, order, raw)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if err := writeEvent(ctx, tx, EventUpdated, orderUID, summarize(order, updatedAt)); err != nil {
+		return time.Time{}, false, err
+	}
+	return updatedAt, true, commit(ctx, tx)
+}
+
+// saveOrder записывает заказ во все таблицы; персональные данные шифруются, если задан файл ключей.
+// created — строки orders до этого не было.
+func (db *DB) saveOrder(ctx context.Context, tx pgx.Tx, order model.Order, raw json.RawMessage) (_ time.Time, created bool, _ error) {
+	dateCreated, err := time.Parse(time.RFC3339, order.DateCreated)
+	if err != nil {
+		dateCreated = time.Now().UTC()
+	}
+
+	order, raw, sealed, err := db.seal(order, raw)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("encrypt order: %w", err)
+	}
+
+	var updatedAt time.Time
+	insertCtx, span := startSpan(ctx, "INSERT", "orders")
+	err = tx.QueryRow(insertCtx,
 		`INSERT INTO orders (
 			order_uid,
 			track_number,
@@ -59,9 +164,11 @@ func (db *DB) SaveOrder(ctx context.Context, order model.Order, raw json.RawMess
 			sm_id,
 			date_created,
 			oof_shard,
-			raw
+			raw,
+			dek,
+			dek_key_id
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
 		) ON CONFLICT (order_uid) DO UPDATE SET
 			track_number = EXCLUDED.track_number,
 			entry = EXCLUDED.entry,
@@ -73,7 +180,11 @@ func (db *DB) SaveOrder(ctx context.Context, order model.Order, raw json.RawMess
 			sm_id = EXCLUDED.sm_id,
 			date_created = EXCLUDED.date_created,
 			oof_shard = EXCLUDED.oof_shard,
-			raw = EXCLUDED.raw`,
+			raw = EXCLUDED.raw,
+			dek = EXCLUDED.dek,
+			dek_key_id = EXCLUDED.dek_key_id,
+			updated_at = now()
+		RETURNING updated_at, xmax = 0`,
 		order.OrderUID,
 		order.TrackNumber,
 		order.Entry,
@@ -86,26 +197,28 @@ func (db *DB) SaveOrder(ctx context.Context, order model.Order, raw json.RawMess
 		dateCreated,
 		order.OofShard,
 		raw,
-	)
+		sealed.dek,
+		sealed.keyID,
+	).Scan(&updatedAt, &created) // xmax = 0 только у вставленной строки
+	tracing.End(span, err)
 	if err != nil {
-		return err
+		return time.Time{}, false, err
 	}
 
-	if err := db.saveDelivery(ctx, tx, order); err != nil {
-		return err
+	if err := db.saveDelivery(ctx, tx, order, sealed); err != nil {
+		return time.Time{}, false, err
 	}
 	if err := db.savePayment(ctx, tx, order); err != nil {
-		return err
+		return time.Time{}, false, err
 	}
-	if err := db.saveItems(ctx, tx, order); err != nil {
-		return err
-	}
-
-	return tx.Commit(ctx)
+	return updatedAt, created, db.saveItems(ctx, tx, order)
 }
 
-func (db *DB) saveDelivery(ctx context.Context, tx pgx.Tx, order model.Order) error {
-	_, err := tx.Exec(ctx,
+func (db *DB) saveDelivery(ctx context.Context, tx pgx.Tx, order model.Order, sealed sealing) (err error) {
+	ctx, span := startSpan(ctx, "INSERT", "deliveries")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.Exec(ctx,
 		`INSERT INTO deliveries (
 			order_uid,
 			name,
@@ -114,9 +227,11 @@ func (db *DB) saveDelivery(ctx context.Context, tx pgx.Tx, order model.Order) er
 			city,
 			address,
 			region,
-			email
+			email,
+			email_bidx,
+			phone_bidx
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 		) ON CONFLICT (order_uid) DO UPDATE SET
 			name = EXCLUDED.name,
 			phone = EXCLUDED.phone,
@@ -124,7 +239,9 @@ func (db *DB) saveDelivery(ctx context.Context, tx pgx.Tx, order model.Order) er
 			city = EXCLUDED.city,
 			address = EXCLUDED.address,
 			region = EXCLUDED.region,
-			email = EXCLUDED.email`,
+			email = EXCLUDED.email,
+			email_bidx = EXCLUDED.email_bidx,
+			phone_bidx = EXCLUDED.phone_bidx`,
 		order.OrderUID,
 		order.Delivery.Name,
 		order.Delivery.Phone,
@@ -133,12 +250,17 @@ func (db *DB) saveDelivery(ctx context.Context, tx pgx.Tx, order model.Order) er
 		order.Delivery.Address,
 		order.Delivery.Region,
 		order.Delivery.Email,
+		sealed.emailIdx,
+		sealed.phoneIdx,
 	)
 	return err
 }
 
-func (db *DB) savePayment(ctx context.Context, tx pgx.Tx, order model.Order) error {
-	_, err := tx.Exec(ctx,
+func (db *DB) savePayment(ctx context.Context, tx pgx.Tx, order model.Order) (err error) {
+	ctx, span := startSpan(ctx, "INSERT", "payments")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.Exec(ctx,
 		`INSERT INTO payments (
 			order_uid,
 			transaction,
@@ -179,7 +301,11 @@ func (db *DB) savePayment(ctx context.Context, tx pgx.Tx, order model.Order) err
 	return err
 }
 
-func (db *DB) saveItems(ctx context.Context, tx pgx.Tx, order model.Order) error {
+func (db *DB) saveItems(ctx context.Context, tx pgx.Tx, order model.Order) (err error) {
+	ctx, span := startSpan(ctx, "INSERT", "items")
+	span.SetAttributes(attribute.Int("order.items", len(order.Items)))
+	defer func() { tracing.End(span, err) }()
+
 	if _, err := tx.Exec(ctx, `DELETE FROM items WHERE order_uid = $1`, order.OrderUID); err != nil {
 		return err
 	}
@@ -222,21 +348,26 @@ func (db *DB) saveItems(ctx context.Context, tx pgx.Tx, order model.Order) error
 	return nil
 }
 
-func (db *DB) GetAllOrders(ctx context.Context) (map[string]json.RawMessage, error) {
-	rows, err := db.pool.Query(ctx, `SELECT order_uid, raw FROM orders`)
+func (db *DB) GetAllOrders(ctx context.Context) (map[string]StoredOrder, error) {
+	rows, err := db.pool.Query(ctx, `SELECT order_uid, raw, updated_at, dek, dek_key_id FROM orders`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	data := make(map[string]json.RawMessage)
+	data := make(map[string]StoredOrder)
 	for rows.Next() {
 		var id string
-		var raw json.RawMessage
-		if err := rows.Scan(&id, &raw); err != nil {
+		var stored StoredOrder
+		var dek []byte
+		var keyID *string
+		if err := rows.Scan(&id, &stored.Raw, &stored.UpdatedAt, &dek, &keyID); err != nil {
 			return nil, err
 		}
-		data[id] = raw
+		if stored.Raw, err = db.open(id, stored.Raw, dek, keyID); err != nil {
+			return nil, fmt.Errorf("decrypt order %s: %w", id, err)
+		}
+		data[id] = stored
 	}
 
 	if err := rows.Err(); err != nil {
@@ -246,11 +377,156 @@ func (db *DB) GetAllOrders(ctx context.Context) (map[string]json.RawMessage, err
 	return data, nil
 }
 
-func (db *DB) GetOrder(ctx context.Context, orderUID string) (json.RawMessage, error) {
-	var raw json.RawMessage
-	err := db.pool.QueryRow(ctx, `SELECT raw FROM orders WHERE order_uid = $1`, orderUID).Scan(&raw)
+// ListOrderIDs возвращает до limit идентификаторов заказов, больших after, по возрастанию.
+func (db *DB) ListOrderIDs(ctx context.Context, after string, limit int) ([]string, error) {
+	rows, err := db.pool.Query(ctx,
+		`SELECT order_uid FROM orders WHERE order_uid > $1 ORDER BY order_uid LIMIT $2`,
+		after,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// GetOrder читает заказ по order_uid. Если заказа нет, Raw будет nil.
+func (db *DB) GetOrder(ctx context.Context, orderUID string) (StoredOrder, error) {
+	var stored StoredOrder
+	var dek []byte
+	var keyID *string
+	err := db.pool.QueryRow(ctx, `SELECT raw, updated_at, dek, dek_key_id FROM orders WHERE order_uid = $1`, orderUID).Scan(&stored.Raw, &stored.UpdatedAt, &dek, &keyID)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil
+		return StoredOrder{}, nil
+	}
+	if err != nil {
+		return StoredOrder{}, err
+	}
+	if stored.Raw, err = db.open(orderUID, stored.Raw, dek, keyID); err != nil {
+		return StoredOrder{}, fmt.Errorf("decrypt order %s: %w", orderUID, err)
+	}
+	return stored, nil
+}
+
+// OrderExists сообщает, есть ли заказ в БД.
+func (db *DB) OrderExists(ctx context.Context, orderUID string) (bool, error) {
+	var exists bool
+	err := db.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, orderUID).Scan(&exists)
+	return exists, err
+}
+
+// erasedPlaceholder подставляется вместо персональных данных при стирании.
+const erasedPlaceholder = "[erased]"
+
+// DeleteOrder удаляет заказ вместе со связанными строками (ON DELETE CASCADE)
+// и пишет запись в audit_log и событие deleted. Возвращает false, если заказа не было.
+func (db *DB) DeleteOrder(ctx context.Context, orderUID, actor string) (bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM orders WHERE order_uid = $1`, orderUID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := writeAudit(ctx, tx, "delete_order", orderUID, 1, actor); err != nil {
+		return false, err
+	}
+	if err := writeEvent(ctx, tx, EventDeleted, orderUID, nil); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// EraseCustomer обезличивает ФИО, телефон, email и адрес во всех заказах
+// покупателя — и в deliveries, и внутри raw — и пишет для каждого событие updated.
+// Возвращает затронутые order_uid.
+func (db *DB) EraseCustomer(ctx context.Context, customerID, actor string) ([]string, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return raw, err
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`UPDATE orders SET raw = jsonb_set(
+			raw,
+			'{delivery}',
+			COALESCE(raw->'delivery', '{}'::jsonb) || jsonb_build_object(
+				'name', $2::text,
+				'phone', $2::text,
+				'email', $2::text,
+				'address', $2::text
+			)
+		), updated_at = now()
+		WHERE customer_id = $1
+		RETURNING order_uid, raw, updated_at`,
+		customerID,
+		erasedPlaceholder,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type erased struct {
+		uid       string
+		raw       json.RawMessage
+		updatedAt time.Time
+	}
+	orders, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (erased, error) {
+		var e erased
+		err := row.Scan(&e.uid, &e.raw, &e.updatedAt)
+		return e, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(orders))
+	for _, e := range orders {
+		// Сводка события не содержит персональных данных, поэтому raw не расшифровывается.
+		var order model.Order
+		if err := json.Unmarshal(e.raw, &order); err != nil {
+			return nil, fmt.Errorf("erase %s: %w", e.uid, err)
+		}
+		if err := writeEvent(ctx, tx, EventUpdated, e.uid, summarize(order, e.updatedAt)); err != nil {
+			return nil, err
+		}
+		ids = append(ids, e.uid)
+	}
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE deliveries SET
+			name = $2,
+			phone = $2,
+			email = $2,
+			address = $2,
+			email_bidx = NULL,
+			phone_bidx = NULL
+		WHERE order_uid = ANY($1)`,
+		ids,
+		erasedPlaceholder,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := writeAudit(ctx, tx, "erase_customer", customerID, len(ids), actor); err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit(ctx)
+}
+
+func writeAudit(ctx context.Context, tx pgx.Tx, action, subject string, affected int, actor string) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO audit_log (action, subject, orders_affected, actor) VALUES ($1, $2, $3, $4)`,
+		action,
+		subject,
+		affected,
+		actor,
+	)
+	return err
 }
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// saveDuration — длительность транзакции SaveOrder с учётом коммита.
+var saveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "l0_db_save_order_duration_seconds",
+	Help:    "Duration of the SaveOrder transaction, by result (ok or error).",
+	Buckets: prometheus.DefBuckets,
+}, []string{"result"})
+
+// observeSave записывает длительность SaveOrder; вызывается через defer с адресом возвращаемой ошибки.
+func observeSave(start time.Time, err *error) {
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	saveDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+var (
+	poolAcquiredDesc = prometheus.NewDesc("l0_db_pool_acquired_conns", "Connections currently acquired from the pool.", nil, nil)
+	poolIdleDesc     = prometheus.NewDesc("l0_db_pool_idle_conns", "Idle connections in the pool.", nil, nil)
+	poolTotalDesc    = prometheus.NewDesc("l0_db_pool_total_conns", "Total connections in the pool.", nil, nil)
+	poolMaxDesc      = prometheus.NewDesc("l0_db_pool_max_conns", "Maximum size of the pool.", nil, nil)
+	poolAcquireDesc  = prometheus.NewDesc("l0_db_pool_acquires_total", "Successful connection acquires.", nil, nil)
+	poolWaitDesc     = prometheus.NewDesc("l0_db_pool_acquire_waits_total", "Acquires that had to wait for a connection.", nil, nil)
+	poolWaitTimeDesc = prometheus.NewDesc("l0_db_pool_acquire_wait_seconds_total", "Total time spent acquiring connections.", nil, nil)
+)
+
+// poolCollector снимает статистику pgxpool в момент запроса метрик.
+type poolCollector struct {
+	db *DB
+}
+
+// Collector возвращает сборщик метрик пула соединений для регистрации в Prometheus.
+func (db *DB) Collector() prometheus.Collector {
+	return poolCollector{db: db}
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredDesc
+	ch <- poolIdleDesc
+	ch <- poolTotalDesc
+	ch <- poolMaxDesc
+	ch <- poolAcquireDesc
+	ch <- poolWaitDesc
+	ch <- poolWaitTimeDesc
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.db.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDesc, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWaitDesc, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWaitTimeDesc, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"L0/internal/model"
+	"L0/internal/tracing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Типы событий о заказах в order_events.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// OrderSummary — краткие сведения о заказе для внешних потребителей, без персональных данных.
+type OrderSummary struct {
+	TrackNumber     string    `json:"track_number"`
+	Locale          string    `json:"locale"`
+	DeliveryService string    `json:"delivery_service"`
+	DateCreated     string    `json:"date_created"`
+	Currency        string    `json:"currency"`
+	Amount          int       `json:"amount"`
+	GoodsTotal      int       `json:"goods_total"`
+	Items           int       `json:"items"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// OrderEvent — событие из order_events. ID растёт монотонно и служит потребителям
+// ключом дедупликации: доставка «хотя бы один раз» допускает повторы.
+type OrderEvent struct {
+	ID         int64         `json:"id"`
+	Type       string        `json:"type"`
+	OrderUID   string        `json:"order_uid"`
+	OccurredAt time.Time     `json:"occurred_at"`
+	Order      *OrderSummary `json:"order,omitempty"` // nil у deleted
+}
+
+func summarize(order model.Order, updatedAt time.Time) *OrderSummary {
+	return &OrderSummary{
+		TrackNumber:     order.TrackNumber,
+		Locale:          order.Locale,
+		DeliveryService: order.DeliveryService,
+		DateCreated:     order.DateCreated,
+		Currency:        order.Payment.Currency,
+		Amount:          order.Payment.Amount,
+		GoodsTotal:      order.Payment.GoodsTotal,
+		Items:           len(order.Items),
+		UpdatedAt:       updatedAt,
+	}
+}
+
+// writeEvent добавляет событие в outbox в транзакции изменения заказа и ставит его
+// в очередь доставки каждому webhook, подписанному на этот тип событий.
+func writeEvent(ctx context.Context, tx pgx.Tx, eventType, orderUID string, summary *OrderSummary) (err error) {
+	ctx, span := startSpan(ctx, "INSERT", "order_events")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.Exec(ctx,
+		`WITH event AS (
+			INSERT INTO order_events (event_type, order_uid, summary) VALUES ($1, $2, $3)
+			RETURNING id, event_type
+		)
+		INSERT INTO webhook_deliveries (webhook_id, event_id)
+		SELECT w.id, event.id FROM webhooks w, event
+		WHERE event.event_type = ANY(w.events)`,
+		eventType,
+		orderUID,
+		summary,
+	)
+	return err
+}
+
+// RelayEvents берёт до limit неотправленных событий в порядке id, передаёт их publish
+// и отмечает отправленными те, что publish принял. Строки блокируются до конца
+// транзакции (SKIP LOCKED), поэтому несколько экземпляров сервиса не отправляют
+// одно событие одновременно. Если publish вернул ошибку, остальные события пачки
+// остаются в outbox до следующего вызова.
+func (db *DB) RelayEvents(ctx context.Context, limit int, publish func(OrderEvent) error) (int, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT id, event_type, order_uid, summary, created_at
+		FROM order_events
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (OrderEvent, error) {
+		var ev OrderEvent
+		var summary []byte
+		if err := row.Scan(&ev.ID, &ev.Type, &ev.OrderUID, &summary, &ev.OccurredAt); err != nil {
+			return OrderEvent{}, err
+		}
+		if summary != nil {
+			ev.Order = new(OrderSummary)
+			if err := json.Unmarshal(summary, ev.Order); err != nil {
+				return OrderEvent{}, err
+			}
+		}
+		return ev, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := make([]int64, 0, len(events))
+	var publishErr error
+	for _, ev := range events {
+		if publishErr = publish(ev); publishErr != nil {
+			break
+		}
+		sent = append(sent, ev.ID)
+	}
+	if len(sent) > 0 {
+		if _, err := tx.Exec(ctx, `UPDATE order_events SET sent_at = now() WHERE id = ANY($1)`, sent); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return len(sent), publishErr
+}
+
+// PruneEvents удаляет до limit отправленных событий, созданных раньше before.
+// Событие с ещё не завершённой доставкой webhook остаётся; завершённые доставки
+// удаляются вместе с ним. Возвращает число удалённых событий.
+func (db *DB) PruneEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tag, err := db.pool.Exec(ctx,
+		`DELETE FROM order_events WHERE id IN (
+			SELECT e.id FROM order_events e
+			WHERE e.sent_at IS NOT NULL AND e.created_at < $1
+				AND NOT EXISTS (
+					SELECT 1 FROM webhook_deliveries d WHERE d.event_id = e.id AND d.status = 'pending'
+				)
+			LIMIT $2
+		)`,
+		before,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
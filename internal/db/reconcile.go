@@ -0,0 +1,158 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"L0/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OrderPair — обе формы заказа: исходный JSON из orders.raw и заказ, собранный из
+// колонок orders и строк deliveries, payments и items. Персональные данные расшифрованы.
+type OrderPair struct {
+	OrderUID   string
+	Raw        json.RawMessage
+	Normalized model.Order
+	// HasDelivery и HasPayment — есть ли строки в deliveries и payments;
+	// без них соответствующие поля Normalized пусты.
+	HasDelivery bool
+	HasPayment  bool
+}
+
+// querier — общее у пула и транзакции.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// LoadOrderPair читает обе формы заказа. Возвращает false, если заказа нет.
+func (db *DB) LoadOrderPair(ctx context.Context, orderUID string) (OrderPair, bool, error) {
+	return db.loadOrderPair(ctx, db.pool, orderUID, "")
+}
+
+// RepairOrder блокирует заказ, передаёт его текущие формы в fix и сохраняет
+// возвращённые заказ и raw тем же путём, что и SaveOrder: все таблицы
+// перезаписываются, updated_at обновляется. Если raw изменился, в той же
+// транзакции пишется событие updated. fix может вернуть nil raw — тогда ничего
+// не меняется. Возвращает false, если заказа нет.
+func (db *DB) RepairOrder(ctx context.Context, orderUID string, fix func(OrderPair) (model.Order, json.RawMessage, error)) (bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	pair, ok, err := db.loadOrderPair(ctx, tx, orderUID, "FOR UPDATE")
+	if err != nil || !ok {
+		return false, err
+	}
+	order, raw, err := fix(pair)
+	if err != nil || raw == nil {
+		return true, err
+	}
+	updatedAt, _, err := db.saveOrder(ctx, tx, order, raw)
+	if err != nil {
+		return true, err
+	}
+	if !bytes.Equal(raw, pair.Raw) {
+		if err := writeEvent(ctx, tx, EventUpdated, orderUID, summarize(order, updatedAt)); err != nil {
+			return true, err
+		}
+	}
+	return true, commit(ctx, tx)
+}
+
+// loadOrderPair читает обе формы заказа через q; lock дописывается к запросу строки orders.
+func (db *DB) loadOrderPair(ctx context.Context, q querier, orderUID, lock string) (OrderPair, bool, error) {
+	pair := OrderPair{OrderUID: orderUID}
+	o := &pair.Normalized
+	var (
+		dateCreated *time.Time
+		dek         []byte
+		keyID       *string
+	)
+	err := q.QueryRow(ctx,
+		`SELECT raw, COALESCE(track_number, ''), COALESCE(entry, ''), COALESCE(locale, ''),
+			COALESCE(internal_signature, ''), COALESCE(customer_id, ''), COALESCE(delivery_service, ''),
+			COALESCE(shardkey, ''), COALESCE(sm_id, 0), date_created, COALESCE(oof_shard, ''), dek, dek_key_id
+		FROM orders WHERE order_uid = $1 `+lock,
+		orderUID,
+	).Scan(&pair.Raw, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerID,
+		&o.DeliveryService, &o.ShardKey, &o.SmID, &dateCreated, &o.OofShard, &dek, &keyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return OrderPair{}, false, nil
+	}
+	if err != nil {
+		return OrderPair{}, false, err
+	}
+	o.OrderUID = orderUID
+	if dateCreated != nil {
+		o.DateCreated = dateCreated.UTC().Format(time.RFC3339)
+	}
+	if pair.Raw, err = db.open(orderUID, pair.Raw, dek, keyID); err != nil {
+		return OrderPair{}, false, fmt.Errorf("decrypt order %s: %w", orderUID, err)
+	}
+
+	d := &o.Delivery
+	err = q.QueryRow(ctx,
+		`SELECT COALESCE(name, ''), COALESCE(phone, ''), COALESCE(zip, ''), COALESCE(city, ''),
+			COALESCE(address, ''), COALESCE(region, ''), COALESCE(email, '')
+		FROM deliveries WHERE order_uid = $1`,
+		orderUID,
+	).Scan(&d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return OrderPair{}, false, err
+	default:
+		pair.HasDelivery = true
+		if err := db.openDelivery(orderUID, d, dek, keyID); err != nil {
+			return OrderPair{}, false, fmt.Errorf("decrypt delivery %s: %w", orderUID, err)
+		}
+	}
+
+	p := &o.Payment
+	err = q.QueryRow(ctx,
+		`SELECT COALESCE(transaction, ''), COALESCE(request_id, ''), COALESCE(currency, ''), COALESCE(provider, ''),
+			COALESCE(amount, 0), COALESCE(payment_dt, 0), COALESCE(bank, ''), COALESCE(delivery_cost, 0),
+			COALESCE(goods_total, 0), COALESCE(custom_fee, 0)
+		FROM payments WHERE order_uid = $1`,
+		orderUID,
+	).Scan(&p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount, &p.PaymentDT, &p.Bank,
+		&p.DeliveryCost, &p.GoodsTotal, &p.CustomFee)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return OrderPair{}, false, err
+	default:
+		pair.HasPayment = true
+	}
+
+	// saveItems вставляет позиции по порядку, поэтому порядок id совпадает с порядком в raw.
+	rows, err := q.Query(ctx,
+		`SELECT COALESCE(chrt_id, 0), COALESCE(track_number, ''), COALESCE(price, 0), COALESCE(rid, ''),
+			COALESCE(name, ''), COALESCE(sale, 0), COALESCE(size, ''), COALESCE(total_price, 0),
+			COALESCE(nm_id, 0), COALESCE(brand, ''), COALESCE(status, 0)
+		FROM items WHERE order_uid = $1 ORDER BY id`,
+		orderUID,
+	)
+	if err != nil {
+		return OrderPair{}, false, err
+	}
+	o.Items, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Item, error) {
+		var it model.Item
+		err := row.Scan(&it.ChrtID, &it.TrackNumber, &it.Price, &it.Rid, &it.Name, &it.Sale, &it.Size,
+			&it.TotalPrice, &it.NmID, &it.Brand, &it.Status)
+		return it, err
+	})
+	if err != nil {
+		return OrderPair{}, false, err
+	}
+	return pair, true, nil
+}
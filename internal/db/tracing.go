@@ -0,0 +1,25 @@
+package db
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("L0/internal/db")
+
+// startSpan начинает клиентский спан запроса к PostgreSQL; table может быть пустой.
+func startSpan(ctx context.Context, operation, table string) (context.Context, trace.Span) {
+	name := operation
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.operation.name", operation),
+	}
+	if table != "" {
+		name += " " + table
+		attrs = append(attrs, attribute.String("db.collection.name", table))
+	}
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Статусы доставки webhook.
+const (
+	DeliveryPending   = "pending"   // ждёт первой или повторной попытки
+	DeliveryDelivered = "delivered" // получатель ответил 2xx
+	DeliveryFailed    = "failed"    // попытки исчерпаны
+)
+
+// Webhook — подписка партнёра на события о заказах.
+type Webhook struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+	// Secret — ключ HMAC-подписи; в списках подписок не отдаётся.
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery — запись журнала доставки события одному webhook.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	OrderUID       string     `json:"order_uid"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// DueDelivery — доставка, взятая в работу: куда и чем подписывать, и само событие.
+type DueDelivery struct {
+	ID       int64
+	Attempts int // с учётом текущей попытки
+	URL      string
+	Secret   string
+	Event    OrderEvent
+}
+
+// DeliveryResult — итог попытки доставки.
+type DeliveryResult struct {
+	Status        string
+	NextAttemptAt time.Time // для pending — когда повторить
+	StatusCode    int       // 0 — ответа не было
+	Error         string
+}
+
+// CreateWebhook сохраняет подписку. С файлом ключей секрет подписи шифруется.
+func (db *DB) CreateWebhook(ctx context.Context, url, secret string, events []string) (Webhook, error) {
+	w := Webhook{URL: url, Secret: secret, Events: events}
+	sealed, dek, keyID, err := db.sealSecret(url, secret)
+	if err != nil {
+		return Webhook{}, err
+	}
+	err = db.pool.QueryRow(ctx,
+		`INSERT INTO webhooks (url, secret, secret_dek, secret_key_id, events) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		url,
+		sealed,
+		dek,
+		keyID,
+		events,
+	).Scan(&w.ID, &w.CreatedAt)
+	return w, err
+}
+
+// ListWebhooks возвращает все подписки в порядке создания, без секретов.
+func (db *DB) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := db.pool.Query(ctx, `SELECT id, url, events, created_at FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Webhook, error) {
+		var w Webhook
+		err := row.Scan(&w.ID, &w.URL, &w.Events, &w.CreatedAt)
+		return w, err
+	})
+}
+
+// DeleteWebhook удаляет подписку вместе с её журналом доставки.
+// Возвращает false, если подписки не было.
+func (db *DB) DeleteWebhook(ctx context.Context, id int64) (bool, error) {
+	tag, err := db.pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// WebhookDeliveries возвращает до limit последних доставок подписки, новые первыми.
+// Возвращает false, если подписки нет.
+func (db *DB) WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, bool, error) {
+	var exists bool
+	if err := db.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)`, webhookID).Scan(&exists); err != nil || !exists {
+		return nil, false, err
+	}
+
+	rows, err := db.pool.Query(ctx,
+		`SELECT d.id, d.event_id, e.event_type, e.order_uid, d.status, d.attempts, d.next_attempt_at,
+			COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.created_at, d.delivered_at
+		FROM webhook_deliveries d
+		JOIN order_events e ON e.id = d.event_id
+		WHERE d.webhook_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2`,
+		webhookID,
+		limit,
+	)
+	if err != nil {
+		return nil, true, err
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (WebhookDelivery, error) {
+		var d WebhookDelivery
+		err := row.Scan(&d.ID, &d.EventID, &d.EventType, &d.OrderUID, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		return d, err
+	})
+	return deliveries, true, err
+}
+
+// ClaimWebhookDeliveries берёт до limit доставок, время которых пришло, увеличивает
+// им счётчик попыток и откладывает следующую попытку на lease: если процесс упадёт
+// посреди отправки, доставка повторится после lease. Строки выбираются через
+// SKIP LOCKED, поэтому несколько экземпляров сервиса не берут одну доставку.
+// Доставки с нерасшифровываемым секретом сразу помечаются неудачными.
+func (db *DB) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := db.pool.Query(ctx,
+		`UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+		FROM webhooks w, order_events e
+		WHERE d.id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= now()
+				ORDER BY next_attempt_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			AND w.id = d.webhook_id
+			AND e.id = d.event_id
+		RETURNING d.id, d.attempts, w.url, w.secret, w.secret_dek, w.secret_key_id,
+			e.id, e.event_type, e.order_uid, e.summary, e.created_at`,
+		limit,
+		lease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (claimedDelivery, error) {
+		var c claimedDelivery
+		err := row.Scan(&c.ID, &c.Attempts, &c.URL, &c.Secret, &c.dek, &c.keyID,
+			&c.Event.ID, &c.Event.Type, &c.Event.OrderUID, &c.summary, &c.Event.OccurredAt)
+		return c, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Доставку, которую не удалось подготовить, повторять бесполезно: она помечается
+	// неудачной с причиной в журнале, а остальные уходят в работу.
+	due, broken := db.openClaimed(claimed)
+	for id, reason := range broken {
+		if err := db.FinishWebhookDelivery(ctx, id, DeliveryResult{Status: DeliveryFailed, Error: reason.Error()}); err != nil {
+			return nil, fmt.Errorf("mark webhook delivery %d failed: %w", id, err)
+		}
+	}
+	return due, nil
+}
+
+// claimedDelivery — строка взятой доставки до расшифровки секрета и разбора события.
+type claimedDelivery struct {
+	DueDelivery
+	dek     []byte
+	keyID   *string
+	summary []byte
+}
+
+// openClaimed расшифровывает секреты и разбирает события взятых доставок.
+// Доставки, для которых это не удалось, возвращаются в broken с причиной.
+func (db *DB) openClaimed(claimed []claimedDelivery) (due []DueDelivery, broken map[int64]error) {
+	due = make([]DueDelivery, 0, len(claimed))
+	for _, c := range claimed {
+		d := c.DueDelivery
+		secret, err := db.openSecret(d.URL, d.Secret, c.dek, c.keyID)
+		if err != nil {
+			err = fmt.Errorf("open webhook secret: %w", err)
+		}
+		d.Secret = secret
+		if err == nil && c.summary != nil {
+			d.Event.Order = new(OrderSummary)
+			if err = json.Unmarshal(c.summary, d.Event.Order); err != nil {
+				err = fmt.Errorf("decode event summary: %w", err)
+			}
+		}
+		if err != nil {
+			if broken == nil {
+				broken = make(map[int64]error)
+			}
+			broken[d.ID] = err
+			continue
+		}
+		due = append(due, d)
+	}
+	return due, broken
+}
+
+// FinishWebhookDelivery записывает итог попытки доставки.
+func (db *DB) FinishWebhookDelivery(ctx context.Context, id int64, res DeliveryResult) error {
+	var code *int
+	if res.StatusCode != 0 {
+		code = &res.StatusCode
+	}
+	var nextAttempt *time.Time
+	if res.Status == DeliveryPending {
+		nextAttempt = &res.NextAttemptAt
+	}
+	// Если подписку удалили, пока шла отправка, строки уже нет — обновлять нечего.
+	_, err := db.pool.Exec(ctx,
+		`UPDATE webhook_deliveries SET
+			status = $2,
+			next_attempt_at = COALESCE($3, next_attempt_at),
+			last_status_code = $4,
+			last_error = NULLIF($5, ''),
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() END
+		WHERE id = $1`,
+		id,
+		res.Status,
+		nextAttempt,
+		code,
+		res.Error,
+	)
+	return err
+}
+
+// PruneWebhookDeliveries удаляет до limit доставленных и окончательно неудачных
+// доставок, созданных раньше before. Ожидающие доставки не трогаются.
+// Возвращает число удалённых строк.
+func (db *DB) PruneWebhookDeliveries(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tag, err := db.pool.Exec(ctx,
+		`DELETE FROM webhook_deliveries WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status IN ('delivered', 'failed') AND created_at < $1
+			LIMIT $2
+		)`,
+		before,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
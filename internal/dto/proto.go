@@ -0,0 +1,116 @@
+package dto
+
+import "L0/internal/orderpb"
+
+// ToProto конвертирует DTO в protobuf-сообщение.
+func ToProto(o Order) *orderpb.Order {
+	items := make([]*orderpb.Item, len(o.Items))
+	for i, it := range o.Items {
+		items[i] = &orderpb.Item{
+			ChrtId:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		}
+	}
+
+	return &orderpb.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &orderpb.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &orderpb.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmID),
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+	}
+}
+
+// FromProto конвертирует protobuf-сообщение в DTO.
+func FromProto(p *orderpb.Order) Order {
+	items := make([]Item, len(p.GetItems()))
+	for i, it := range p.GetItems() {
+		items[i] = Item{
+			ChrtID:      int(it.GetChrtId()),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       int(it.GetPrice()),
+			Rid:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  int(it.GetTotalPrice()),
+			NmID:        int(it.GetNmId()),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		}
+	}
+
+	d, pay := p.GetDelivery(), p.GetPayment()
+	return Order{
+		OrderUID:    p.GetOrderUid(),
+		TrackNumber: p.GetTrackNumber(),
+		Entry:       p.GetEntry(),
+		Delivery: Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		},
+		Payment: Payment{
+			Transaction:  pay.GetTransaction(),
+			RequestID:    pay.GetRequestId(),
+			Currency:     pay.GetCurrency(),
+			Provider:     pay.GetProvider(),
+			Amount:       int(pay.GetAmount()),
+			PaymentDT:    pay.GetPaymentDt(),
+			Bank:         pay.GetBank(),
+			DeliveryCost: int(pay.GetDeliveryCost()),
+			GoodsTotal:   int(pay.GetGoodsTotal()),
+			CustomFee:    int(pay.GetCustomFee()),
+		},
+		Items:             items,
+		Locale:            p.GetLocale(),
+		InternalSignature: p.GetInternalSignature(),
+		CustomerID:        p.GetCustomerId(),
+		DeliveryService:   p.GetDeliveryService(),
+		ShardKey:          p.GetShardkey(),
+		SmID:              int(p.GetSmId()),
+		DateCreated:       p.GetDateCreated(),
+		OofShard:          p.GetOofShard(),
+	}
+}
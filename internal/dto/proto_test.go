@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"reflect"
+	"testing"
+
+	"L0/internal/orderpb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	order := Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery:    Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Email: "test@gmail.com"},
+		Payment:     Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Amount: 1817, PaymentDT: 1637907727, DeliveryCost: 1500},
+		Items: []Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Name: "Mascaras", Sale: 30, TotalPrice: 317, NmID: 2389212, Status: 202},
+		},
+		Locale:      "en",
+		CustomerID:  "test",
+		ShardKey:    "9",
+		SmID:        99,
+		DateCreated: "2021-11-26T06:22:19Z",
+		OofShard:    "1",
+	}
+
+	data, err := proto.Marshal(ToProto(order))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded orderpb.Order
+	if err := proto.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if got := FromProto(&decoded); !reflect.DeepEqual(got, order) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, order)
+	}
+}
+
+func TestFromProtoEmpty(t *testing.T) {
+	got := FromProto(&orderpb.Order{OrderUid: "1"})
+	if got.OrderUID != "1" || got.Items == nil {
+		t.Fatalf("unexpected conversion: %+v", got)
+	}
+}
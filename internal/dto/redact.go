@@ -0,0 +1,159 @@
+package dto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Action — что сделать с полем заказа при выдаче по профилю.
+type Action string
+
+const (
+	// ActionMask заменяет символы звёздочками, оставляя Keep последних; у email
+	// остаются первая буква имени и домен.
+	ActionMask Action = "mask"
+	// ActionHash заменяет значение на первые 16 hex-символов HMAC-SHA256: одинаковые
+	// значения можно сопоставить между заказами, но не восстановить.
+	ActionHash Action = "hash"
+	// ActionDrop обнуляет поле.
+	ActionDrop Action = "drop"
+)
+
+// Rule — правило для одного поля. Field — путь из JSON-имён полей через точку:
+// "delivery.phone", "payment.transaction", "items.name" (для всех товаров).
+type Rule struct {
+	Field  string
+	Action Action
+	Keep   int
+}
+
+// Profile — именованный набор правил маскирования для потребителя или роли.
+type Profile struct {
+	Name  string
+	Rules []Rule
+	// HashKey — ключ HMAC для ActionHash. Без него короткие значения вроде
+	// телефонов восстанавливаются перебором.
+	HashKey []byte
+}
+
+// Validate проверяет, что все поля профиля существуют, а mask и hash применяются к строкам.
+func (p Profile) Validate() error {
+	if p.Name == "" {
+		return errors.New("profile name is required")
+	}
+	for _, r := range p.Rules {
+		t, err := fieldType(reflect.TypeOf(Order{}), strings.Split(r.Field, "."))
+		if err != nil {
+			return fmt.Errorf("profile %q: field %q: %w", p.Name, r.Field, err)
+		}
+		switch r.Action {
+		case ActionDrop:
+		case ActionMask, ActionHash:
+			if t.Kind() != reflect.String {
+				return fmt.Errorf("profile %q: field %q: %s applies only to strings", p.Name, r.Field, r.Action)
+			}
+		default:
+			return fmt.Errorf("profile %q: field %q: unknown action %q", p.Name, r.Field, r.Action)
+		}
+	}
+	return nil
+}
+
+// Apply возвращает копию заказа с применёнными правилами профиля.
+// Профиль должен пройти Validate, иначе неизвестные поля пропускаются.
+func (p Profile) Apply(o Order) Order {
+	o.Items = slices.Clone(o.Items) // товары меняются на месте, не трогаем исходный срез
+	v := reflect.ValueOf(&o).Elem()
+	for _, r := range p.Rules {
+		walk(v, strings.Split(r.Field, "."), func(f reflect.Value) {
+			switch r.Action {
+			case ActionDrop:
+				f.SetZero()
+			case ActionMask:
+				f.SetString(mask(f.String(), r.Keep))
+			case ActionHash:
+				if f.String() != "" {
+					f.SetString(p.hash(f.String()))
+				}
+			}
+		})
+	}
+	return o
+}
+
+func (p Profile) hash(s string) string {
+	mac := hmac.New(sha256.New, p.HashKey)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// fieldType находит тип поля по пути из JSON-имён; срезы в середине пути проходятся насквозь.
+func fieldType(t reflect.Type, path []string) (reflect.Type, error) {
+	if len(path) == 0 {
+		return t, nil
+	}
+	for t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%q is not an object", path[0])
+	}
+	for i := range t.NumField() {
+		if jsonName(t.Field(i)) == path[0] {
+			return fieldType(t.Field(i).Type, path[1:])
+		}
+	}
+	return nil, fmt.Errorf("unknown field %q", path[0])
+}
+
+// walk вызывает fn для значения по пути; срезы в середине пути обходятся поэлементно.
+func walk(v reflect.Value, path []string, fn func(reflect.Value)) {
+	if len(path) == 0 {
+		fn(v)
+		return
+	}
+	if v.Kind() == reflect.Slice {
+		for i := range v.Len() {
+			walk(v.Index(i), path, fn)
+		}
+		return
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := range t.NumField() {
+		if jsonName(t.Field(i)) == path[0] {
+			walk(v.Field(i), path[1:], fn)
+			return
+		}
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name
+}
+
+// mask заменяет звёздочками все символы, кроме последних keep; у email оставляет
+// первую букву имени и домен: t***@gmail.com.
+func mask(s string, keep int) string {
+	if local, domain, ok := strings.Cut(s, "@"); ok {
+		r := []rune(local)
+		if len(r) <= 1 {
+			return "*@" + domain
+		}
+		return string(r[0]) + strings.Repeat("*", len(r)-1) + "@" + domain
+	}
+	r := []rune(s)
+	if len(r) <= keep {
+		return strings.Repeat("*", len(r))
+	}
+	return strings.Repeat("*", len(r)-keep) + string(r[len(r)-keep:])
+}
@@ -0,0 +1,65 @@
+package dto
+
+import "testing"
+
+func TestProfileApply(t *testing.T) {
+	o := Order{
+		OrderUID:          "b563feb7b2b84b6test",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		Delivery:          Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com"},
+		Payment:           Payment{Transaction: "b563feb7b2b84b6test"},
+		Items:             []Item{{Name: "Mascaras"}, {Name: "Brush"}},
+	}
+	p := Profile{Name: "partner", HashKey: []byte("k"), Rules: []Rule{
+		{Field: "delivery.phone", Action: ActionMask, Keep: 2},
+		{Field: "delivery.email", Action: ActionMask},
+		{Field: "payment.transaction", Action: ActionMask, Keep: 4},
+		{Field: "customer_id", Action: ActionHash},
+		{Field: "internal_signature", Action: ActionDrop},
+		{Field: "items.name", Action: ActionDrop},
+	}}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	got := p.Apply(o)
+	if got.Delivery.Phone != "*********00" || got.Delivery.Email != "t***@gmail.com" || got.Payment.Transaction != "***************test" {
+		t.Fatalf("unexpected masking: %+v %+v", got.Delivery, got.Payment)
+	}
+	if got.InternalSignature != "" || got.Items[0].Name != "" || got.Items[1].Name != "" {
+		t.Fatalf("fields not dropped: %+v", got)
+	}
+	if got.CustomerID == "test" || len(got.CustomerID) != 16 || got.CustomerID != p.Apply(o).CustomerID {
+		t.Fatalf("customer_id must be a stable hash, got %q", got.CustomerID)
+	}
+	if got.Delivery.Name != "Test Testov" || got.OrderUID != o.OrderUID {
+		t.Fatal("fields outside the profile must be kept")
+	}
+	if o.Items[0].Name != "Mascaras" || o.Delivery.Phone != "+9720000000" {
+		t.Fatal("Apply must not modify the source order")
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	cases := []Profile{
+		{},
+		{Name: "x", Rules: []Rule{{Field: "delivery.fax", Action: ActionDrop}}},
+		{Name: "x", Rules: []Rule{{Field: "payment.amount", Action: ActionMask}}},
+		{Name: "x", Rules: []Rule{{Field: "delivery", Action: ActionHash}}},
+		{Name: "x", Rules: []Rule{{Field: "locale", Action: "encrypt"}}},
+	}
+	for _, p := range cases {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("expected error for %+v", p)
+		}
+	}
+
+	drop := Profile{Name: "x", Rules: []Rule{{Field: "items", Action: ActionDrop}, {Field: "payment.amount", Action: ActionDrop}}}
+	if err := drop.Validate(); err != nil {
+		t.Fatalf("drop of non-string fields must be allowed: %v", err)
+	}
+	if got := drop.Apply(Order{Items: []Item{{}}}); got.Items != nil {
+		t.Fatalf("items not dropped: %+v", got.Items)
+	}
+}
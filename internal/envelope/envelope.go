@@ -0,0 +1,42 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Типы содержимого сообщения с заказом.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Envelope — конверт сообщения NATS Streaming. STAN не поддерживает заголовки,
+// поэтому тип содержимого и метаданные передаются внутри самого сообщения.
+type Envelope struct {
+	ContentType string            `json:"content_type"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Payload     []byte            `json:"payload"` // в JSON кодируется base64
+}
+
+// Wrap упаковывает payload в конверт.
+func Wrap(contentType string, payload []byte, headers map[string]string) ([]byte, error) {
+	return json.Marshal(Envelope{ContentType: contentType, Headers: headers, Payload: payload})
+}
+
+// Unwrap распаковывает сообщение. Сообщение без конверта (обычный JSON заказа)
+// возвращается как есть с типом ContentTypeJSON.
+func Unwrap(data []byte) Envelope {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var probe struct {
+			ContentType string            `json:"content_type"`
+			Headers     map[string]string `json:"headers"`
+			Payload     *[]byte           `json:"payload"`
+		}
+		if err := json.Unmarshal(trimmed, &probe); err == nil && probe.ContentType != "" && probe.Payload != nil {
+			return Envelope{ContentType: probe.ContentType, Headers: probe.Headers, Payload: *probe.Payload}
+		}
+	}
+	return Envelope{ContentType: ContentTypeJSON, Payload: data}
+}
@@ -0,0 +1,44 @@
+package envelope
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrapUnwrap(t *testing.T) {
+	payload := []byte{0x0a, 0x03, 'u', 'i', 'd'}
+	data, err := Wrap(ContentTypeProtobuf, payload, map[string]string{"traceparent": "00-abc"})
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+
+	env := Unwrap(data)
+	if env.ContentType != ContentTypeProtobuf {
+		t.Fatalf("unexpected content type %q", env.ContentType)
+	}
+	if !bytes.Equal(env.Payload, payload) {
+		t.Fatalf("unexpected payload %v", env.Payload)
+	}
+	if env.Headers["traceparent"] != "00-abc" {
+		t.Fatalf("headers lost: %v", env.Headers)
+	}
+}
+
+func TestUnwrapPlainOrder(t *testing.T) {
+	plain := []byte(`{"order_uid":"b563feb7b2b84b6test","payment":{"transaction":"x"}}`)
+
+	env := Unwrap(plain)
+	if env.ContentType != ContentTypeJSON {
+		t.Fatalf("unexpected content type %q", env.ContentType)
+	}
+	if !bytes.Equal(env.Payload, plain) {
+		t.Fatal("plain order must pass through unchanged")
+	}
+}
+
+func TestUnwrapGarbage(t *testing.T) {
+	garbage := []byte("not json")
+	if env := Unwrap(garbage); !bytes.Equal(env.Payload, garbage) || env.ContentType != ContentTypeJSON {
+		t.Fatalf("unexpected envelope %+v", env)
+	}
+}
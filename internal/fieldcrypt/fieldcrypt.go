@@ -0,0 +1,258 @@
+// Package fieldcrypt шифрует отдельные поля заказа по схеме envelope encryption:
+// каждый заказ шифруется своим ключом данных (DEK, AES-256-GCM), а DEK хранится
+// рядом с заказом в зашифрованном мастер-ключом виде. Мастер-ключи лежат в
+// локальном файле; смена мастер-ключа требует только перешифровать DEK.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// prefix отличает зашифрованное значение от открытого текста: строки без него
+// (старые записи, заглушка стирания) возвращаются Open как есть.
+const prefix = "enc:v1:"
+
+// keySize — размер мастер-ключей, ключей данных и ключа слепых индексов (AES-256).
+const keySize = 32
+
+// ErrUnknownKey — DEK зашифрован мастер-ключом, которого нет в файле ключей.
+var ErrUnknownKey = errors.New("unknown master key")
+
+// keyfile — формат файла ключей. Ключи закодированы в base64.
+type keyfile struct {
+	Active     string            `json:"active"`
+	MasterKeys map[string]string `json:"master_keys"`
+	IndexKey   string            `json:"index_key"`
+}
+
+// Keyring — мастер-ключи и ключ слепых индексов.
+type Keyring struct {
+	active string
+	master map[string][]byte
+	index  []byte
+}
+
+// LoadKeyring читает файл ключей.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyfile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	k := &Keyring{active: f.Active, master: make(map[string][]byte, len(f.MasterKeys))}
+	for id, enc := range f.MasterKeys {
+		if k.master[id], err = decodeKey(enc); err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+	}
+	if _, ok := k.master[k.active]; !ok {
+		return nil, fmt.Errorf("active master key %q is not in master_keys", k.active)
+	}
+	if k.index, err = decodeKey(f.IndexKey); err != nil {
+		return nil, fmt.Errorf("index key: %w", err)
+	}
+	return k, nil
+}
+
+// NewKeyring создаёт файл ключей с одним мастер-ключом и ключом слепых индексов.
+func NewKeyring(activeID string) (*Keyring, error) {
+	k := &Keyring{master: map[string][]byte{}}
+	if err := k.Rotate(activeID); err != nil {
+		return nil, err
+	}
+	k.index = make([]byte, keySize)
+	if _, err := rand.Read(k.index); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate добавляет новый мастер-ключ id и делает его активным. Старые ключи
+// остаются, чтобы можно было расшифровать ещё не перешифрованные DEK.
+func (k *Keyring) Rotate(id string) error {
+	if _, ok := k.master[id]; ok {
+		return fmt.Errorf("master key %q already exists", id)
+	}
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	k.master[id] = key
+	k.active = id
+	return nil
+}
+
+// ActiveKeyID возвращает идентификатор мастер-ключа, которым шифруются новые DEK.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// WriteFile сохраняет ключи в файл с правами 0600.
+func (k *Keyring) WriteFile(path string) error {
+	f := keyfile{Active: k.active, MasterKeys: map[string]string{}, IndexKey: base64.StdEncoding.EncodeToString(k.index)}
+	for id, key := range k.master {
+		f.MasterKeys[id] = base64.StdEncoding.EncodeToString(key)
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// NewDataKey создаёт DEK и возвращает его вместе с зашифрованной копией и
+// идентификатором мастер-ключа, которым она зашифрована.
+func (k *Keyring) NewDataKey() (dek, wrapped []byte, keyID string, err error) {
+	dek = make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, nil, "", err
+	}
+	wrapped, err = seal(k.master[k.active], dek, []byte(k.active))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return dek, wrapped, k.active, nil
+}
+
+// UnwrapDataKey расшифровывает DEK мастер-ключом keyID.
+func (k *Keyring) UnwrapDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	master, ok := k.master[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return open(master, wrapped, []byte(keyID))
+}
+
+// Rewrap перешифровывает DEK активным мастер-ключом.
+func (k *Keyring) Rewrap(keyID string, wrapped []byte) ([]byte, string, error) {
+	dek, err := k.UnwrapDataKey(keyID, wrapped)
+	if err != nil {
+		return nil, "", err
+	}
+	rewrapped, err := seal(k.master[k.active], dek, []byte(k.active))
+	return rewrapped, k.active, err
+}
+
+// Seal шифрует значение поля ключом данных. aad привязывает шифротекст к заказу
+// и полю, чтобы его нельзя было незаметно перенести в другую запись.
+// Пустая строка не шифруется.
+func Seal(dek []byte, value, aad string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	ct, err := seal(dek, []byte(value), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.RawStdEncoding.EncodeToString(ct), nil
+}
+
+// Open расшифровывает значение, полученное от Seal. Значения без префикса
+// возвращаются как есть.
+func Open(dek []byte, value, aad string) (string, error) {
+	enc, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+	ct, err := base64.RawStdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", err
+	}
+	pt, err := open(dek, ct, []byte(aad))
+	return string(pt), err
+}
+
+// Типы слепых индексов; входят в HMAC, чтобы одинаковые строки в разных полях давали разные индексы.
+const (
+	IndexEmail = "email"
+	IndexPhone = "phone"
+)
+
+// BlindIndex возвращает HMAC-SHA256 нормализованного значения: по нему можно
+// искать точное совпадение, не расшифровывая записи. Для пустого значения — "".
+func (k *Keyring) BlindIndex(kind, value string) string {
+	value = normalize(kind, value)
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(kind + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// normalize приводит email к нижнему регистру, а из телефона оставляет только цифры,
+// чтобы "+7 (900) 000-00-00" и "79000000000" находились одинаково.
+func normalize(kind, value string) string {
+	switch kind {
+	case IndexEmail:
+		return strings.ToLower(strings.TrimSpace(value))
+	case IndexPhone:
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, value)
+	default:
+		return value
+	}
+}
+
+func decodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// seal шифрует AES-256-GCM; результат — nonce || ciphertext.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,99 @@
+package fieldcrypt
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	k, err := NewKeyring("k1")
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	dek, _, _, err := k.NewDataKey()
+	if err != nil {
+		t.Fatalf("data key: %v", err)
+	}
+
+	sealed, err := Seal(dek, "+9720000000", "order-1/phone")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if !strings.HasPrefix(sealed, prefix) || strings.Contains(sealed, "972") {
+		t.Fatalf("unexpected ciphertext %q", sealed)
+	}
+	if got, err := Open(dek, sealed, "order-1/phone"); err != nil || got != "+9720000000" {
+		t.Fatalf("open: %q, %v", got, err)
+	}
+
+	// Шифротекст, перенесённый в другое поле или заказ, не расшифровывается.
+	if _, err := Open(dek, sealed, "order-2/phone"); err == nil {
+		t.Fatal("expected aad mismatch")
+	}
+	// Открытый текст и пустые значения проходят без изменений.
+	if got, _ := Open(dek, "[erased]", "order-1/phone"); got != "[erased]" {
+		t.Fatalf("plaintext changed: %q", got)
+	}
+	if got, _ := Seal(dek, "", "order-1/phone"); got != "" {
+		t.Fatalf("empty value must stay empty, got %q", got)
+	}
+}
+
+func TestRotateAndRewrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	k, err := NewKeyring("k1")
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	dek, wrapped, keyID, err := k.NewDataKey()
+	if err != nil || keyID != "k1" {
+		t.Fatalf("data key: %s %v", keyID, err)
+	}
+
+	if err := k.Rotate("k2"); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := k.WriteFile(path); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	loaded, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loaded.ActiveKeyID() != "k2" {
+		t.Fatalf("active key %q", loaded.ActiveKeyID())
+	}
+
+	rewrapped, newID, err := loaded.Rewrap(keyID, wrapped)
+	if err != nil || newID != "k2" {
+		t.Fatalf("rewrap: %s %v", newID, err)
+	}
+	got, err := loaded.UnwrapDataKey(newID, rewrapped)
+	if err != nil || string(got) != string(dek) {
+		t.Fatalf("rewrapped key differs: %v", err)
+	}
+	if _, err := loaded.UnwrapDataKey("k3", rewrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+	if loaded.BlindIndex(IndexEmail, "a@b.c") != k.BlindIndex(IndexEmail, "a@b.c") {
+		t.Fatal("index key must survive reload")
+	}
+}
+
+func TestBlindIndexNormalization(t *testing.T) {
+	k, _ := NewKeyring("k1")
+	if k.BlindIndex(IndexEmail, "Test@Gmail.com ") != k.BlindIndex(IndexEmail, "test@gmail.com") {
+		t.Fatal("email index must ignore case and spaces")
+	}
+	if k.BlindIndex(IndexPhone, "+7 (900) 000-00-00") != k.BlindIndex(IndexPhone, "79000000000") {
+		t.Fatal("phone index must ignore formatting")
+	}
+	if k.BlindIndex(IndexPhone, "79000000000") == k.BlindIndex(IndexEmail, "79000000000") {
+		t.Fatal("indexes of different kinds must differ")
+	}
+	if k.BlindIndex(IndexEmail, "") != "" {
+		t.Fatal("empty value must have no index")
+	}
+}
@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"L0/internal/auth"
+	"L0/internal/orderpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyMetadata — ключ метаданных gRPC с API-ключом (аналог заголовка X-API-Key).
+const apiKeyMetadata = "x-api-key"
+
+// methodRoles — минимальная роль для методов OrderService, та же, что у HTTP-маршрутов:
+// чтение заказов и лента — reader, выгрузка всех заказов подряд — как поиск, support.
+var methodRoles = map[string]auth.Role{
+	orderpb.OrderService_GetOrder_FullMethodName:       auth.RoleReader,
+	orderpb.OrderService_BatchGetOrders_FullMethodName: auth.RoleReader,
+	orderpb.OrderService_ListOrders_FullMethodName:     auth.RoleSupport,
+	orderpb.OrderService_WatchOrders_FullMethodName:    auth.RoleReader,
+}
+
+// requiredRole возвращает роль для метода; false — метод доступен без учётных данных.
+// Health нужен оркестратору без ключей; reflection и прочие неописанные методы требуют reader.
+func requiredRole(fullMethod string) (auth.Role, bool) {
+	if strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return "", false
+	}
+	if role, ok := methodRoles[fullMethod]; ok {
+		return role, true
+	}
+	return auth.RoleReader, true
+}
+
+// authenticate проверяет метаданные вызова и возвращает контекст с Principal.
+func authenticate(ctx context.Context, a *auth.Authenticator, fullMethod string) (context.Context, error) {
+	role, required := requiredRole(fullMethod)
+	if !required {
+		return ctx, nil
+	}
+	if a == nil {
+		return nil, status.Error(codes.Unauthenticated, "authentication is not configured")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, err := a.AuthenticateHeaders(first(md, apiKeyMetadata), first(md, "authorization"))
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		return nil, status.Error(codes.Unauthenticated, "missing api key or bearer token")
+	case err != nil:
+		return nil, status.Error(codes.Unauthenticated, "invalid api key or bearer token")
+	case !p.Role.Allows(role):
+		return nil, status.Error(codes.PermissionDenied, "role "+string(role)+" required")
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// unaryAuth — перехватчик unary-вызовов с проверкой учётных данных и роли.
+func unaryAuth(a *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuth — то же для потоковых вызовов; Principal доступен через stream.Context().
+func streamAuth(a *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authStream подменяет контекст потока контекстом с Principal.
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,229 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"L0/internal/auth"
+	"L0/internal/cache"
+	"L0/internal/dto"
+	"L0/internal/orderpb"
+	"L0/internal/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	maxBatchSize    = 500
+	// watchBuffer — сколько событий копится для медленного клиента WatchOrders.
+	watchBuffer = 64
+)
+
+// Server реализует orderpb.OrderServiceServer поверх service.OrderService.
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	orders   *service.OrderService
+	profiles service.Profiles
+	done     chan struct{} // закрывается в Shutdown, чтобы завершить WatchOrders
+}
+
+// NewServer создаёт сервис заказов. Ответы и события WatchOrders маскируются
+// профилем вызывающего клиента так же, как в HTTP API.
+func NewServer(orders *service.OrderService, profiles service.Profiles) *Server {
+	return &Server{orders: orders, profiles: profiles, done: make(chan struct{})}
+}
+
+// NewGRPCServer создаёт grpc.Server с сервисом заказов, health checking и reflection.
+// Вызовы, кроме health, проверяются тем же Authenticator, что и HTTP API: метаданные
+// x-api-key или authorization ("Bearer <jwt>"); nil — такие вызовы получают Unauthenticated.
+func NewGRPCServer(srv *Server, a *auth.Authenticator) (*grpc.Server, *health.Server) {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryAuth(a)),
+		grpc.StreamInterceptor(streamAuth(a)),
+	)
+	orderpb.RegisterOrderServiceServer(s, srv)
+
+	hs := health.NewServer()
+	hs.SetServingStatus(orderpb.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+
+	reflection.Register(s)
+	return s, hs
+}
+
+// Shutdown завершает открытые потоки WatchOrders, чтобы GracefulStop не ждал их вечно.
+func (s *Server) Shutdown() {
+	close(s.done)
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	profile, err := s.profileFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := s.orders.GetByID(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return s.toProto(req.GetOrderUid(), entry, profile)
+}
+
+func (s *Server) BatchGetOrders(ctx context.Context, req *orderpb.BatchGetOrdersRequest) (*orderpb.BatchGetOrdersResponse, error) {
+	if len(req.GetOrderUids()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d order_uids per batch", maxBatchSize)
+	}
+	profile, err := s.profileFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &orderpb.BatchGetOrdersResponse{}
+	for _, id := range req.GetOrderUids() {
+		entry, err := s.orders.GetByID(ctx, id)
+		if errors.Is(err, service.ErrOrderNotFound) {
+			resp.Missing = append(resp.Missing, id)
+			continue
+		}
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		order, err := s.toProto(id, entry, profile)
+		if err != nil {
+			return nil, err
+		}
+		resp.Orders = append(resp.Orders, order)
+	}
+	return resp, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	profile, err := s.profileFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := base64.RawURLEncoding.DecodeString(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	ids, entries, next, err := s.orders.ListOrders(ctx, string(after), pageSize)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &orderpb.ListOrdersResponse{}
+	for i, entry := range entries {
+		order, err := s.toProto(ids[i], entry, profile)
+		if err != nil {
+			return nil, err
+		}
+		resp.Orders = append(resp.Orders, order)
+	}
+	if next != "" {
+		resp.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(next))
+	}
+	return resp, nil
+}
+
+func (s *Server) WatchOrders(req *orderpb.WatchOrdersRequest, stream grpc.ServerStreamingServer[orderpb.Order]) error {
+	profile, err := s.profileFor(stream.Context())
+	if err != nil {
+		return err
+	}
+	events := s.orders.Events()
+	sub := events.Subscribe(watchBuffer, service.Filter{
+		DeliveryService: req.GetDeliveryService(),
+		Locale:          req.GetLocale(),
+		CustomerID:      req.GetCustomerId(),
+	})
+	defer events.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case ev, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			order, err := s.toProto(ev.OrderUID, ev.Entry, profile)
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(order); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcError переводит доменную ошибку сервиса в статус gRPC.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidID), errors.Is(err, service.ErrValidationFailed):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrStorageUnavailable):
+		slog.Error("grpc: storage unavailable", "err", err)
+		return status.Error(codes.Unavailable, "storage unavailable")
+	default:
+		slog.Error("grpc: request failed", "err", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// profileFor определяет профиль маскирования клиента, которого аутентифицировал
+// перехватчик. Клиент с ненастроенным профилем получает PermissionDenied.
+func (s *Server) profileFor(ctx context.Context) (*dto.Profile, error) {
+	profile, ok := s.profiles.For(auth.FromContext(ctx))
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "unknown redaction profile")
+	}
+	return profile, nil
+}
+
+// toProto маскирует заказ профилем клиента и переводит его в protobuf.
+func (s *Server) toProto(id string, entry cache.Entry, profile *dto.Profile) (*orderpb.Order, error) {
+	entry, err := s.orders.RedactedFor(id, entry, profile)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "corrupted order")
+	}
+	return toProto(entry)
+}
+
+// toProto переводит закэшированный нормализованный JSON в protobuf.
+func toProto(entry cache.Entry) (*orderpb.Order, error) {
+	var order dto.Order
+	if err := json.Unmarshal(entry.Data, &order); err != nil {
+		return nil, status.Error(codes.Internal, "corrupted order")
+	}
+	return dto.ToProto(order), nil
+}
@@ -0,0 +1,192 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"L0/internal/auth"
+	"L0/internal/cache"
+	"L0/internal/dto"
+	"L0/internal/orderpb"
+	"L0/internal/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const cachedOrder = `{"order_uid":"order-1","track_number":"WBILMTESTTRACK","delivery":{"name":"Test Testov","phone":"+9720000000"},"payment":{"amount":1817},"items":[{"chrt_id":9934930}]}`
+
+// startServer поднимает gRPC-сервер поверх in-memory соединения. БД не нужна:
+// заказы заранее лежат в кэше, а события публикуются напрямую. API-ключи "reader"
+// и "support" выданы клиентам с одноимёнными ролями; роли reader назначен профиль,
+// маскирующий телефон.
+func startServer(t *testing.T) (*service.OrderService, orderpb.OrderServiceClient, *grpc.ClientConn) {
+	t.Helper()
+
+	c := cache.New()
+	c.Set("order-1", cache.Entry{Data: []byte(cachedOrder)})
+	orders := service.NewOrderService(nil, c)
+
+	var keys []auth.APIKey
+	for _, role := range []auth.Role{auth.RoleReader, auth.RoleSupport} {
+		keys = append(keys, auth.APIKey{Name: string(role), Role: role, SHA256: auth.HashKey(string(role))})
+	}
+	keys = append(keys, auth.APIKey{Name: "misconfigured", Role: auth.RoleReader, SHA256: auth.HashKey("misconfigured"), Profile: "missing"})
+	authenticator, err := auth.New(keys, auth.JWTConfig{})
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	profiles, err := service.NewProfiles([]dto.Profile{
+		{Name: "reader", Rules: []dto.Rule{{Field: "delivery.phone", Action: dto.ActionMask, Keep: 2}}},
+	}, map[auth.Role]string{auth.RoleReader: "reader"})
+	if err != nil {
+		t.Fatalf("profiles: %v", err)
+	}
+
+	srv := NewServer(orders, profiles)
+	gs, _ := NewGRPCServer(srv, authenticator)
+	lis := bufconn.Listen(1 << 20)
+	go gs.Serve(lis)
+	t.Cleanup(func() {
+		srv.Shutdown()
+		gs.Stop()
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return orders, orderpb.NewOrderServiceClient(conn), conn
+}
+
+// withKey добавляет API-ключ в метаданные вызова.
+func withKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, key)
+}
+
+func TestGetOrderFromCache(t *testing.T) {
+	_, client, _ := startServer(t)
+
+	order, err := client.GetOrder(withKey(context.Background(), "reader"), &orderpb.GetOrderRequest{OrderUid: "order-1"})
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if order.GetDelivery().GetName() != "Test Testov" || order.GetPayment().GetAmount() != 1817 || len(order.GetItems()) != 1 {
+		t.Fatalf("unexpected order %v", order)
+	}
+}
+
+func TestGetOrderRequiresID(t *testing.T) {
+	_, client, _ := startServer(t)
+
+	_, err := client.GetOrder(withKey(context.Background(), "reader"), &orderpb.GetOrderRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestWatchOrdersStreamsEvents(t *testing.T) {
+	orders, client, _ := startServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchOrders(withKey(ctx, "reader"), &orderpb.WatchOrdersRequest{})
+	if err != nil {
+		t.Fatalf("WatchOrders: %v", err)
+	}
+
+	// Подписка на сервере появляется асинхронно — публикуем, пока событие не дойдёт.
+	received := make(chan *orderpb.Order, 1)
+	go func() {
+		if order, err := stream.Recv(); err == nil {
+			received <- order
+		}
+	}()
+	for {
+		orders.Events().Publish(service.Event{OrderUID: "order-1", Entry: cache.Entry{Data: []byte(cachedOrder)}})
+		select {
+		case order := <-received:
+			if order.GetOrderUid() != "order-1" || order.GetDelivery().GetPhone() == "+9720000000" {
+				t.Fatalf("unexpected order %v", order)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no order received")
+		}
+	}
+}
+
+func TestHealthServing(t *testing.T) {
+	_, _, conn := startServer(t)
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("unexpected status %v", resp.GetStatus())
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	_, client, _ := startServer(t)
+	ctx := context.Background()
+	req := &orderpb.GetOrderRequest{OrderUid: "order-1"}
+
+	if _, err := client.GetOrder(ctx, req); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("no credentials: expected Unauthenticated, got %v", err)
+	}
+	if _, err := client.GetOrder(withKey(ctx, "wrong"), req); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unknown key: expected Unauthenticated, got %v", err)
+	}
+	if _, err := client.ListOrders(withKey(ctx, "reader"), &orderpb.ListOrdersRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("reader listing orders: expected PermissionDenied, got %v", err)
+	}
+
+	// У потока ошибка приходит на первом Recv.
+	stream, err := client.WatchOrders(ctx, &orderpb.WatchOrdersRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("stream without credentials: expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	_, client, _ := startServer(t)
+	ctx := context.Background()
+	req := &orderpb.GetOrderRequest{OrderUid: "order-1"}
+
+	order, err := client.GetOrder(withKey(ctx, "reader"), req)
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	if phone := order.GetDelivery().GetPhone(); phone != "*********00" {
+		t.Fatalf("reader sees phone %q", phone)
+	}
+
+	order, err = client.GetOrder(withKey(ctx, "support"), req)
+	if err != nil {
+		t.Fatalf("support: %v", err)
+	}
+	if phone := order.GetDelivery().GetPhone(); phone != "+9720000000" {
+		t.Fatalf("support sees phone %q", phone)
+	}
+
+	if _, err := client.GetOrder(withKey(ctx, "misconfigured"), req); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("unknown profile: expected PermissionDenied, got %v", err)
+	}
+}
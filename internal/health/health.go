@@ -0,0 +1,148 @@
+// Package health проверяет готовность сервиса: зависимости (БД, NATS) и
+// внутренние условия вроде завершённого прогрева кэша.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Статусы проверки и сервиса в целом.
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDegraded = "degraded" // сервис работает, но с отклонениями (например, отстаёт от очереди)
+	StatusDraining = "draining" // сервис останавливается и не принимает новую нагрузку
+)
+
+// ErrNotReady возвращает Flag, пока условие не выполнено.
+var ErrNotReady = errors.New("not ready")
+
+// ErrDegraded оборачивают ошибки проверок, которые не должны снимать сервис с трафика:
+// такая проверка получает статус degraded, а готовность сохраняется.
+var ErrDegraded = errors.New("degraded")
+
+// Check проверяет одну зависимость; nil — зависимость доступна.
+type Check func(ctx context.Context) error
+
+// Result — результат одной проверки.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	err       error
+}
+
+// Err возвращает ошибку проверки; в JSON она не попадает, чтобы не раскрывать подробности.
+func (r Result) Err() error {
+	return r.err
+}
+
+// Report — состояние сервиса и всех проверок.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Ready сообщает, можно ли направлять на сервис трафик.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker хранит проверки и признак остановки сервиса.
+type Checker struct {
+	timeout time.Duration
+
+	mu       sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// New создаёт Checker; каждая проверка ограничена timeout.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add регистрирует проверку; можно вызывать после запуска HTTP-сервера, когда
+// зависимость появилась.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name, check})
+}
+
+// Drain переводит сервис в состояние остановки: Run больше не сообщает готовность.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Run выполняет все проверки параллельно и собирает отчёт.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			start := time.Now()
+			err := nc.check(ctx)
+			results[i] = Result{
+				Status:    StatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+				err:       err,
+			}
+			switch {
+			case errors.Is(err, ErrDegraded):
+				results[i].Status = StatusDegraded
+			case err != nil:
+				results[i].Status = StatusFailing
+			}
+		})
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, nc := range checks {
+		report.Checks[nc.name] = results[i]
+		switch {
+		case results[i].Status == StatusFailing:
+			report.Status = StatusFailing
+		case results[i].Status == StatusDegraded && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	if c.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// Flag — проверка условия, которое однажды становится выполненным (например,
+// прогрев кэша): до вызова Set она возвращает ErrNotReady.
+type Flag struct {
+	set atomic.Bool
+}
+
+// Set отмечает условие выполненным.
+func (f *Flag) Set() {
+	f.set.Store(true)
+}
+
+// Check реализует Check.
+func (f *Flag) Check(context.Context) error {
+	if !f.set.Load() {
+		return ErrNotReady
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCheckerRun(t *testing.T) {
+	c := New(50 * time.Millisecond)
+	var warmed Flag
+	c.Add("cache", warmed.Check)
+	c.Add("postgres", func(context.Context) error { return nil })
+	c.Add("nats", func(ctx context.Context) error {
+		<-ctx.Done() // зависшая зависимость ограничена таймаутом
+		return ctx.Err()
+	})
+
+	report := c.Run(context.Background())
+	if report.Ready() || report.Status != StatusFailing {
+		t.Fatalf("expected failing report, got %q", report.Status)
+	}
+	if got := report.Checks["cache"]; got.Status != StatusFailing || !errors.Is(got.Err(), ErrNotReady) {
+		t.Fatalf("cache: %+v", got)
+	}
+	if got := report.Checks["nats"]; !errors.Is(got.Err(), context.DeadlineExceeded) {
+		t.Fatalf("nats: %+v", got)
+	}
+	if got := report.Checks["postgres"]; got.Status != StatusOK {
+		t.Fatalf("postgres: %+v", got)
+	}
+}
+
+func TestCheckerDrain(t *testing.T) {
+	c := New(time.Second)
+	var warmed Flag
+	c.Add("cache", warmed.Check)
+	warmed.Set()
+
+	if report := c.Run(context.Background()); !report.Ready() {
+		t.Fatalf("expected ready, got %q", report.Status)
+	}
+	c.Drain()
+	if report := c.Run(context.Background()); report.Ready() || report.Status != StatusDraining {
+		t.Fatalf("expected draining, got %q", report.Status)
+	}
+}
+
+func TestCheckerDegraded(t *testing.T) {
+	c := New(time.Second)
+	lagging := fmt.Errorf("%w: lag 1500 > 1000", ErrDegraded)
+	c.Add("nats_lag", func(context.Context) error { return lagging })
+	c.Add("postgres", func(context.Context) error { return nil })
+
+	report := c.Run(context.Background())
+	if !report.Ready() || report.Status != StatusDegraded {
+		t.Fatalf("expected ready degraded report, got %q", report.Status)
+	}
+	if got := report.Checks["nats_lag"]; got.Status != StatusDegraded || !errors.Is(got.Err(), ErrDegraded) {
+		t.Fatalf("nats_lag: %+v", got)
+	}
+
+	// Отказ важнее отклонения.
+	c.Add("cache", new(Flag).Check)
+	if report := c.Run(context.Background()); report.Ready() || report.Status != StatusFailing {
+		t.Fatalf("expected failing report, got %q", report.Status)
+	}
+}
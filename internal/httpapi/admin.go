@@ -0,0 +1,214 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"L0/internal/auth"
+	"L0/internal/db"
+	"L0/internal/nats"
+	"L0/internal/service"
+	"L0/internal/webhook"
+)
+
+// deleteOrderHandler обрабатывает DELETE /orders/{id}.
+func deleteOrderHandler(orders *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if err := orders.DeleteOrder(r.Context(), id, auth.FromContext(r.Context()).Subject); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "order deleted", "order_uid", id, "actor", auth.FromContext(r.Context()).Subject)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// maxPatchBody ограничивает размер тела PATCH-запроса.
+const maxPatchBody = 1 << 20
+
+// patchOrderHandler обрабатывает PATCH /orders/{id} с телом application/merge-patch+json.
+func patchOrderHandler(orders *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/merge-patch+json" {
+			w.Header().Set("Accept-Patch", "application/merge-patch+json")
+			writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "expected application/merge-patch+json")
+			return
+		}
+
+		patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBody))
+		if err != nil {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, "request body too large")
+			return
+		}
+
+		entry, err := orders.PatchOrder(r.Context(), id, patch, r.Header.Get("If-Match"))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "order patched", "order_uid", id, "actor", auth.FromContext(r.Context()).Subject)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", entry.ETag)
+		w.Header().Set("Last-Modified", entry.ModTime.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		w.Write(entry.Data)
+	}
+}
+
+// eraseCustomerHandler обрабатывает POST /customers/{id}/erase — «право на забвение».
+func eraseCustomerHandler(orders *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		customerID := r.PathValue("id")
+		affected, err := orders.EraseCustomer(r.Context(), customerID, auth.FromContext(r.Context()).Subject)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// customer_id скрывается логгером: после стирания он остаётся только в audit_log.
+		slog.InfoContext(r.Context(), "customer erased", "customer_id", customerID, "orders_affected", affected, "actor", auth.FromContext(r.Context()).Subject)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"customer_id":     customerID,
+			"orders_affected": affected,
+		})
+	}
+}
+
+// natsLagHandler обрабатывает GET /admin/nats/lag: отставание от канала NATS по последнему опросу мониторинга.
+func natsLagHandler(m *nats.LagMonitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m == nil {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "lag monitoring is disabled")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(m.Lag())
+	}
+}
+
+// maxWebhookBody ограничивает размер тела POST /admin/webhooks.
+const maxWebhookBody = 64 << 10
+
+// defaultDeliveriesLimit — сколько доставок отдаёт журнал без параметра limit.
+const defaultDeliveriesLimit = 50
+
+// webhookRequest — тело POST /admin/webhooks.
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// webhooksDisabled отвечает 404, если подписки не настроены; возвращает true, если ответ уже отправлен.
+func webhooksDisabled(w http.ResponseWriter, r *http.Request, s *webhook.Service) bool {
+	if s == nil {
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, "webhooks are disabled")
+		return true
+	}
+	return false
+}
+
+// createWebhookHandler обрабатывает POST /admin/webhooks. Секрет подписи отдаётся только в этом ответе.
+func createWebhookHandler(s *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if webhooksDisabled(w, r, s) {
+			return
+		}
+		var req webhookRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&req); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid JSON body")
+			return
+		}
+		created, err := s.Create(r.Context(), req.URL, req.Events)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "webhook created", "webhook_id", created.ID, "url", created.URL, "events", created.Events, "actor", auth.FromContext(r.Context()).Subject)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// listWebhooksHandler обрабатывает GET /admin/webhooks.
+func listWebhooksHandler(s *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if webhooksDisabled(w, r, s) {
+			return
+		}
+		ws, err := s.List(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if ws == nil {
+			ws = []db.Webhook{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"webhooks": ws})
+	}
+}
+
+// deleteWebhookHandler обрабатывает DELETE /admin/webhooks/{id}.
+func deleteWebhookHandler(s *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if webhooksDisabled(w, r, s) {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid webhook id")
+			return
+		}
+		if err := s.Delete(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		slog.InfoContext(r.Context(), "webhook deleted", "webhook_id", id, "actor", auth.FromContext(r.Context()).Subject)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// webhookDeliveriesHandler обрабатывает GET /admin/webhooks/{id}/deliveries — журнал доставки, новые первыми.
+func webhookDeliveriesHandler(s *webhook.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if webhooksDisabled(w, r, s) {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid webhook id")
+			return
+		}
+		limit := defaultDeliveriesLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			// Диапазон уже проверен по спецификации.
+			if limit, err = strconv.Atoi(v); err != nil {
+				writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, "invalid limit")
+				return
+			}
+		}
+		ds, err := s.Deliveries(r.Context(), id, limit)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if ds == nil {
+			ds = []db.WebhookDelivery{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]any{"deliveries": ds})
+	}
+}
@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"L0/internal/auth"
+)
+
+// authResult — итог проверки учётных данных запроса.
+type authResult struct {
+	principal auth.Principal
+	err       error
+}
+
+type authResultKey struct{}
+
+// authenticate один раз проверяет учётные данные запроса до лимитов и кладёт
+// результат в контекст. Сам запрос не отклоняет: это делает requireRole на
+// маршрутах, которым нужна роль, до чтения тела валидатором.
+func authenticate(a *auth.Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, err := a.Authenticate(r)
+		ctx := context.WithValue(r.Context(), authResultKey{}, authResult{principal: p, err: err})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// verifiedPrincipal возвращает клиента, чьи учётные данные уже проверил authenticate.
+func verifiedPrincipal(r *http.Request) (auth.Principal, bool) {
+	res, ok := r.Context().Value(authResultKey{}).(authResult)
+	return res.principal, ok && res.err == nil
+}
+
+// requireRole пропускает запрос, только если клиент аутентифицирован и его роли
+// достаточно для эндпоинта. Principal передаётся обработчику через контекст.
+func requireRole(a *auth.Authenticator, role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a == nil {
+			writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "authentication is not configured")
+			return
+		}
+		res, ok := r.Context().Value(authResultKey{}).(authResult)
+		if !ok {
+			res.principal, res.err = a.Authenticate(r)
+		}
+		p, err := res.principal, res.err
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="l0"`)
+			detail := "missing api key or bearer token"
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				detail = "invalid api key or bearer token"
+			}
+			writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, detail)
+			return
+		}
+		if !p.Role.Allows(role) {
+			writeProblem(w, r, http.StatusForbidden, codeForbidden, "role "+string(role)+" required")
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	}
+}
@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"L0/internal/health"
+)
+
+// healthzHandler обрабатывает GET /healthz: процесс жив, пока отвечает на HTTP.
+func healthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+	}
+}
+
+// readyzHandler обрабатывает GET /readyz: 200, только если все проверки прошли
+// и сервис не останавливается.
+func readyzHandler(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := runChecks(r, checker)
+		writeHealth(w, healthStatus(report), map[string]string{"status": report.Status})
+	}
+}
+
+// healthHandler обрабатывает GET /health: статус и задержка каждой зависимости.
+func healthHandler(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := runChecks(r, checker)
+		writeHealth(w, healthStatus(report), report)
+	}
+}
+
+// runChecks выполняет проверки; без Checker сервис считается готовым.
+// Причины отказов пишутся в лог: в ответ они не попадают.
+func runChecks(r *http.Request, checker *health.Checker) health.Report {
+	if checker == nil {
+		return health.Report{Status: health.StatusOK, Checks: map[string]health.Result{}}
+	}
+	report := checker.Run(r.Context())
+	for name, res := range report.Checks {
+		if err := res.Err(); err != nil {
+			slog.DebugContext(r.Context(), "health check failed", "check", name, "err", err)
+		}
+	}
+	return report
+}
+
+func healthStatus(report health.Report) int {
+	if report.Ready() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func writeHealth(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
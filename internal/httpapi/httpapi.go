@@ -0,0 +1,146 @@
+// Package httpapi собирает HTTP API сервиса заказов: маршруты, обработчики,
+// ошибки в формате problem+json и проверку запросов по спецификации OpenAPI.
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"L0/internal/auth"
+	"L0/internal/health"
+	"L0/internal/nats"
+	"L0/internal/openapi"
+	"L0/internal/ratelimit"
+	"L0/internal/requestid"
+	"L0/internal/service"
+	"L0/internal/webhook"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Config — настройки HTTP API.
+type Config struct {
+	// Auth проверяет API-ключи и JWT; nil — все эндпоинты, кроме спецификации и статики, отвечают 401.
+	Auth *auth.Authenticator
+	// OrderCacheControl — значение Cache-Control для GET /orders/{id}.
+	OrderCacheControl string
+	// Profiles — профили маскирования персональных данных; нулевое значение — все
+	// клиенты получают заказ целиком.
+	Profiles service.Profiles
+	// RateLimits — лимиты частоты запросов по шаблону маршрута ("GET /orders/{id}");
+	// для остальных маршрутов действует DefaultRateLimit. Нулевой Limit отключает ограничение.
+	RateLimits       map[string]ratelimit.Limit
+	DefaultRateLimit ratelimit.Limit
+	// Health — проверки для /readyz и /health; nil — сервис всегда готов.
+	Health *health.Checker
+	// Lag — отставание подписки NATS для GET /admin/nats/lag; nil — эндпоинт отвечает 404.
+	Lag *nats.LagMonitor
+	// Webhooks — подписки для /admin/webhooks; nil — эндпоинты отвечают 404.
+	Webhooks *webhook.Service
+	// StaticDir — каталог статического фронта, который отдаётся по GET /; пусто — без статики.
+	StaticDir string
+}
+
+// maxRequestBody ограничивает тело любого запроса ещё до проверки по спецификации;
+// обработчики могут ограничивать его сильнее.
+const maxRequestBody = 1 << 20
+
+// New возвращает обработчик всего HTTP API поверх orders.
+func New(orders *service.OrderService, cfg Config) (http.Handler, error) {
+	// Запросы к API проверяются по спецификации OpenAPI до попадания в обработчики.
+	spec, err := openapi.Load()
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	validate, err := openapi.Validator(spec, func(w http.ResponseWriter, r *http.Request, err error) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, "request body too large")
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openapi validator: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	for _, rt := range apiRoutes(orders, cfg) {
+		// Тело читается валидатором, поэтому сначала проверяются роль и размер тела.
+		h := limitBody(maxRequestBody, validate(rt.handler))
+		if rt.role != "" {
+			h = requireRole(cfg.Auth, rt.role, h.ServeHTTP)
+		}
+		limit, ok := cfg.RateLimits[rt.pattern]
+		if !ok {
+			limit = cfg.DefaultRateLimit
+		}
+		if limit != (ratelimit.Limit{}) {
+			h = rateLimit(ratelimit.New(rt.pattern, limit), h)
+		}
+		mux.Handle(rt.pattern, h)
+	}
+	// Метрики Prometheus не входят в API и не описаны в спецификации; по ним видны
+	// маршруты и объёмы трафика, поэтому они доступны только администратору.
+	mux.Handle("GET /metrics", requireRole(cfg.Auth, auth.RoleAdmin, promhttp.Handler().ServeHTTP))
+	// Статика регистрируется только для GET, поэтому другие методы на неизвестных
+	// путях получают 405, а не содержимое каталога.
+	if cfg.StaticDir != "" {
+		mux.Handle("GET /", http.FileServer(http.Dir(cfg.StaticDir)))
+	}
+
+	// requestid снаружи, чтобы идентификатор запроса попал и в журнал запросов.
+	// Учётные данные проверяются до лимитов: корзины заводятся только на проверенных клиентов.
+	return requestid.Middleware(instrument(mux, authenticate(cfg.Auth, problemMux{mux}))), nil
+}
+
+// limitBody ограничивает тело запроса n байтами: чтение сверх лимита возвращает *http.MaxBytesError.
+func limitBody(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// problemMux отвечает problem+json, когда ни один маршрут не подошёл: 404 для
+// неизвестного пути и 405 с заголовком Allow для неподдерживаемого метода.
+type problemMux struct {
+	*http.ServeMux
+}
+
+func (m problemMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := m.Handler(r); pattern != "" {
+		m.ServeMux.ServeHTTP(w, r)
+		return
+	}
+	m.ServeMux.ServeHTTP(&unmatchedWriter{ResponseWriter: w, r: r}, r)
+}
+
+// unmatchedWriter подменяет текстовые ответы 404/405 из ServeMux на problem+json.
+// Заголовок Allow, выставленный ServeMux, сохраняется.
+type unmatchedWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+func (w *unmatchedWriter) WriteHeader(status int) {
+	switch status {
+	case http.StatusNotFound:
+		writeProblem(w.ResponseWriter, w.r, status, codeNotFound, "no such endpoint")
+	case http.StatusMethodNotAllowed:
+		writeProblem(w.ResponseWriter, w.r, status, codeMethodNotAllowed, "allowed methods: "+w.Header().Get("Allow"))
+	default:
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.replaced = true
+}
+
+func (w *unmatchedWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil // тело от http.Error уже заменено problem+json
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,691 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"L0/internal/auth"
+	"L0/internal/cache"
+	"L0/internal/db"
+	"L0/internal/dto"
+	"L0/internal/health"
+	"L0/internal/nats"
+	"L0/internal/ratelimit"
+	"L0/internal/service"
+	"L0/internal/webhook"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const cachedOrder = `{"order_uid":"order-1","track_number":"WBILMTESTTRACK","delivery":{"phone":"+9720000000","email":"test@gmail.com"},"payment":{"transaction":"b563feb7b2b84b6test"}}`
+
+// newTestServer поднимает API поверх кэша без БД: заказ order-1 уже закэширован,
+// ключи "reader", "support" и "admin" выданы клиентам с одноимёнными ролями, а ключи
+// "partner" и "misconfigured" — читателям с отдельными профилями маскирования.
+func newTestServer(t *testing.T, opts ...service.Option) *httptest.Server {
+	t.Helper()
+	return newTestServerWith(t, nil, opts...)
+}
+
+// newTestServerWith — то же, что newTestServer, но configure может поменять настройки API.
+func newTestServerWith(t *testing.T, configure func(*Config), opts ...service.Option) *httptest.Server {
+	t.Helper()
+	return newTestServerOn(t, nil, configure, opts...)
+}
+
+// newTestServerOn — то же, что newTestServerWith, но заказы, которых нет в кэше, ищутся в store.
+func newTestServerOn(t *testing.T, store service.Store, configure func(*Config), opts ...service.Option) *httptest.Server {
+	t.Helper()
+
+	c := cache.New()
+	c.Set("order-1", cache.Entry{Data: []byte(cachedOrder), ETag: `"v1"`})
+	return serveOrders(t, service.NewOrderService(store, c, opts...), configure)
+}
+
+// serveOrders поднимает API поверх orders с ключами и профилями newTestServer.
+func serveOrders(t *testing.T, orders *service.OrderService, configure func(*Config)) *httptest.Server {
+	t.Helper()
+
+	var keys []auth.APIKey
+	for _, role := range []auth.Role{auth.RoleReader, auth.RoleSupport, auth.RoleAdmin} {
+		keys = append(keys, auth.APIKey{Name: string(role), Role: role, SHA256: auth.HashKey(string(role))})
+	}
+	keys = append(keys,
+		auth.APIKey{Name: "partner", Role: auth.RoleReader, SHA256: auth.HashKey("partner"), Profile: "partner"},
+		auth.APIKey{Name: "misconfigured", Role: auth.RoleReader, SHA256: auth.HashKey("misconfigured"), Profile: "missing"},
+	)
+	authenticator, err := auth.New(keys, auth.JWTConfig{})
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	profiles, err := service.NewProfiles([]dto.Profile{
+		{Name: "reader", Rules: []dto.Rule{
+			{Field: "delivery.phone", Action: dto.ActionMask, Keep: 2},
+			{Field: "delivery.email", Action: dto.ActionMask},
+			{Field: "payment.transaction", Action: dto.ActionMask, Keep: 4},
+		}},
+		{Name: "partner", Rules: []dto.Rule{
+			{Field: "delivery", Action: dto.ActionDrop},
+			{Field: "payment.transaction", Action: dto.ActionDrop},
+		}},
+	}, map[auth.Role]string{auth.RoleReader: "reader"})
+	if err != nil {
+		t.Fatalf("profiles: %v", err)
+	}
+	cfg := Config{Auth: authenticator, Profiles: profiles}
+	if configure != nil {
+		configure(&cfg)
+	}
+	handler, err := New(orders, cfg)
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do выполняет запрос с API-ключом key; пустой key — без учётных данных.
+func do(t *testing.T, method, url, key string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if key != "" {
+		req.Header.Set(auth.APIKeyHeader, key)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestGetOrder(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := do(t, http.MethodGet, srv.URL+"/orders/order-1", "support")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Fatal("expected X-Request-ID in response")
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	srv := newTestServerWith(t, func(cfg *Config) { cfg.OrderCacheControl = "private, no-cache" })
+
+	resp, err := do(t, http.MethodGet, srv.URL+"/orders/order-1", "support")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("status %d, etag %q", resp.StatusCode, etag)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/orders/order-1", nil)
+	req.Header.Set(auth.APIKeyHeader, "support")
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("conditional get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified || len(body) != 0 {
+		t.Fatalf("expected empty 304, got %d %q", resp.StatusCode, body)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "private, no-cache" {
+		t.Fatalf("Cache-Control %q", cc)
+	}
+	vary := resp.Header.Get("Vary")
+	for _, h := range []string{"Authorization", auth.APIKeyHeader} {
+		if !strings.Contains(vary, h) {
+			t.Fatalf("Vary %q does not include %s", vary, h)
+		}
+	}
+}
+
+func TestRoutingErrors(t *testing.T) {
+	srv := newTestServer(t, service.WithIDFormat(service.IDFormat{MaxLen: 10, Charset: "abcdefghijklmnopqrstuvwxyz0123456789-"}))
+
+	cases := []struct {
+		name, method, path string
+		status             int
+		code               string
+		allow              string
+	}{
+		{"bad charset", http.MethodGet, "/orders/order%3B1", http.StatusBadRequest, codeInvalidOrderID, ""},
+		{"too long", http.MethodGet, "/orders/" + strings.Repeat("a", 11), http.StatusBadRequest, codeInvalidOrderID, ""},
+		{"nested path", http.MethodGet, "/orders/order-1/items", http.StatusNotFound, codeNotFound, ""},
+		{"wrong method", http.MethodPost, "/orders/order-1", http.StatusMethodNotAllowed, codeMethodNotAllowed, "DELETE, GET, HEAD, PATCH"},
+	}
+	for _, tc := range cases {
+		resp, err := do(t, tc.method, srv.URL+tc.path, "admin")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var p problem
+		err = json.NewDecoder(resp.Body).Decode(&p)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: invalid problem body: %v", tc.name, err)
+		}
+		if resp.StatusCode != tc.status || p.Code != tc.code {
+			t.Fatalf("%s: got %d %q, want %d %q", tc.name, resp.StatusCode, p.Code, tc.status, tc.code)
+		}
+		if allow := resp.Header.Get("Allow"); allow != tc.allow {
+			t.Fatalf("%s: Allow %q, want %q", tc.name, allow, tc.allow)
+		}
+	}
+}
+
+func TestRoles(t *testing.T) {
+	srv := newTestServer(t)
+
+	cases := []struct {
+		name, method, key string
+		status            int
+	}{
+		{"anonymous", http.MethodGet, "", http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "nobody", http.StatusUnauthorized},
+		{"reader reads", http.MethodGet, "reader", http.StatusOK},
+		{"reader deletes", http.MethodDelete, "reader", http.StatusForbidden},
+		{"support deletes", http.MethodDelete, "support", http.StatusForbidden},
+		{"unknown profile", http.MethodGet, "misconfigured", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		resp, err := do(t, tc.method, srv.URL+"/orders/order-1", tc.key)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.status)
+		}
+	}
+
+	resp, err := do(t, http.MethodGet, srv.URL+"/openapi.json", "")
+	if err != nil {
+		t.Fatalf("spec: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("spec must be public, got %d", resp.StatusCode)
+	}
+}
+
+// TestValidationAfterAuth проверяет, что тело запроса читается валидатором
+// спецификации только у клиентов с нужной ролью и не больше maxRequestBody.
+func TestValidationAfterAuth(t *testing.T) {
+	srv := newTestServerWith(t, func(cfg *Config) { cfg.Webhooks = webhook.NewService(&memWebhooks{}) })
+
+	huge := `{"url":"https://203.0.113.10/` + strings.Repeat("a", maxRequestBody) + `"}`
+	for _, tc := range []struct {
+		name, key, body string
+		status          int
+	}{
+		{"anonymous", "", `{"events":"not a list"}`, http.StatusUnauthorized},
+		{"wrong role", "reader", `{"events":"not a list"}`, http.StatusForbidden},
+		{"invalid body", "admin", `{"events":"not a list"}`, http.StatusBadRequest},
+		{"anonymous huge body", "", huge, http.StatusUnauthorized},
+		{"huge body", "admin", huge, http.StatusRequestEntityTooLarge},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/admin/webhooks", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.key != "" {
+			req.Header.Set(auth.APIKeyHeader, tc.key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.status)
+		}
+	}
+}
+
+func TestRedactionProfiles(t *testing.T) {
+	srv := newTestServer(t)
+
+	get := func(key string) (map[string]any, string) {
+		resp, err := do(t, http.MethodGet, srv.URL+"/orders/order-1", key)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		defer resp.Body.Close()
+		var order map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return order, resp.Header.Get("ETag")
+	}
+	field := func(order map[string]any, obj, name string) string {
+		return order[obj].(map[string]any)[name].(string)
+	}
+
+	masked, maskedETag := get("reader")
+	if got := field(masked, "delivery", "phone"); got != "*********00" {
+		t.Fatalf("phone %q", got)
+	}
+	if got := field(masked, "delivery", "email"); got != "t***@gmail.com" {
+		t.Fatalf("email %q", got)
+	}
+	if got := field(masked, "payment", "transaction"); got != "***************test" {
+		t.Fatalf("transaction %q", got)
+	}
+
+	full, fullETag := get("support")
+	if got := field(full, "delivery", "phone"); got != "+9720000000" {
+		t.Fatalf("support must see full phone, got %q", got)
+	}
+	partner, partnerETag := get("partner")
+	if got := field(partner, "delivery", "email"); got != "" {
+		t.Fatalf("partner profile must drop delivery, got email %q", got)
+	}
+	if got := field(partner, "payment", "transaction"); got != "" {
+		t.Fatalf("partner profile must drop transaction, got %q", got)
+	}
+
+	if maskedETag == fullETag || partnerETag == maskedETag || partnerETag == fullETag {
+		t.Fatal("each profile must have its own ETag")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := newTestServerWith(t, func(cfg *Config) {
+		cfg.RateLimits = map[string]ratelimit.Limit{"GET /orders/{id}": {Rate: 0.01, Burst: 2}}
+	})
+
+	for i := range 2 {
+		resp, err := do(t, http.MethodGet, srv.URL+"/orders/order-1", "reader")
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("RateLimit-Remaining") != strconv.Itoa(1-i) {
+			t.Fatalf("request %d: status %d, remaining %q", i, resp.StatusCode, resp.Header.Get("RateLimit-Remaining"))
+		}
+	}
+
+	resp, err := do(t, http.MethodGet, srv.URL+"/orders/order-1", "reader")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	var p problem
+	json.NewDecoder(resp.Body).Decode(&p)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || p.Code != codeRateLimited {
+		t.Fatalf("expected 429 rate_limited, got %d %q", resp.StatusCode, p.Code)
+	}
+	if resp.Header.Get("Retry-After") != "100" || resp.Header.Get("RateLimit-Limit") != "2" {
+		t.Fatalf("unexpected headers: %v", resp.Header)
+	}
+
+	// Другой клиент и маршрут без своего лимита не ограничены.
+	for _, url := range []string{srv.URL + "/orders/order-1", srv.URL + "/openapi.json"} {
+		resp, err := do(t, http.MethodGet, url, "support")
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d", url, resp.StatusCode)
+		}
+	}
+
+	// Непроверенные ключи не получают своих корзин: все они делят корзину IP-адреса.
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		resp, err := do(t, http.MethodGet, srv.URL+"/orders/order-1", "fake-"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("fake key %d: expected %d, got %d", i, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	srv := newTestServer(t)
+	found := httpRequests.WithLabelValues("GET /orders/{id}", "200")
+	unmatched := httpRequests.WithLabelValues(unmatchedRoute, "404")
+	foundBefore, unmatchedBefore := testutil.ToFloat64(found), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/orders/order-1", "/orders/order-1/items"} {
+		resp, err := do(t, http.MethodGet, srv.URL+path, "reader")
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	if got := testutil.ToFloat64(found) - foundBefore; got != 1 {
+		t.Fatalf("expected 1 request for GET /orders/{id}, got %v", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - unmatchedBefore; got != 1 {
+		t.Fatalf("expected 1 unmatched request, got %v", got)
+	}
+
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "support": http.StatusForbidden} {
+		resp, err := do(t, http.MethodGet, srv.URL+"/metrics", key)
+		if err != nil {
+			t.Fatalf("metrics: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("metrics with key %q: expected %d, got %d", key, want, resp.StatusCode)
+		}
+	}
+	resp, err := do(t, http.MethodGet, srv.URL+"/metrics", "admin")
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `l0_http_requests_total{route="GET /orders/{id}",status="200"}`) {
+		t.Fatalf("metrics: status %d, body without request counter", resp.StatusCode)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	srv := newTestServer(t)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/orders/order-1", nil)
+	req.Header.Set(auth.APIKeyHeader, "reader")
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /orders/{id}" || span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != parentID {
+		t.Fatalf("span %q does not continue client trace: trace %s parent %s", span.Name(), span.SpanContext().TraceID(), span.Parent().SpanID())
+	}
+}
+
+func TestHealth(t *testing.T) {
+	checker := health.New(time.Second)
+	var warmed health.Flag
+	checker.Add("cache", warmed.Check)
+	srv := newTestServerWith(t, func(cfg *Config) { cfg.Health = checker })
+
+	get := func(path string) (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path) // без учётных данных
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: invalid body: %v", path, err)
+		}
+		return resp.StatusCode, body
+	}
+
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Fatalf("healthz: %d", status)
+	}
+	if status, body := get("/readyz"); status != http.StatusServiceUnavailable || body["status"] != health.StatusFailing {
+		t.Fatalf("readyz before warm-up: %d %v", status, body)
+	}
+
+	warmed.Set()
+	status, body := get("/health")
+	cache, _ := body["checks"].(map[string]any)["cache"].(map[string]any)
+	if status != http.StatusOK || cache["status"] != health.StatusOK {
+		t.Fatalf("health after warm-up: %d %v", status, body)
+	}
+
+	checker.Drain()
+	if status, body := get("/readyz"); status != http.StatusServiceUnavailable || body["status"] != health.StatusDraining {
+		t.Fatalf("readyz while draining: %d %v", status, body)
+	}
+	if status, _ := get("/healthz"); status != http.StatusOK {
+		t.Fatalf("healthz while draining: %d", status)
+	}
+}
+
+func TestNatsLag(t *testing.T) {
+	// Без монитора эндпоинт есть, но отвечает 404.
+	srv := newTestServer(t)
+	resp, err := do(t, http.MethodGet, srv.URL+"/admin/nats/lag", "admin")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("disabled: status %d", resp.StatusCode)
+	}
+
+	srv = newTestServerWith(t, func(cfg *Config) { cfg.Lag = nats.NewLagMonitor("http://127.0.0.1:0", 1000) })
+	resp, err = do(t, http.MethodGet, srv.URL+"/admin/nats/lag", "support")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("support: status %d, want 403", resp.StatusCode)
+	}
+
+	resp, err = do(t, http.MethodGet, srv.URL+"/admin/nats/lag", "admin")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	var lag nats.Lag
+	if err := json.NewDecoder(resp.Body).Decode(&lag); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !lag.CheckedAt.IsZero() {
+		t.Fatalf("before first poll: %d %+v", resp.StatusCode, lag)
+	}
+}
+
+// memOrders — заказы в памяти для админских эндпоинтов: order_uid → customer_id.
+// Остальные методы service.Store не реализованы.
+type memOrders struct {
+	service.Store
+	customers map[string]string
+}
+
+func (m *memOrders) DeleteOrder(ctx context.Context, uid, actor string) (bool, error) {
+	_, ok := m.customers[uid]
+	delete(m.customers, uid)
+	return ok, nil
+}
+
+func (m *memOrders) EraseCustomer(ctx context.Context, customerID, actor string) ([]string, error) {
+	var ids []string
+	for uid, c := range m.customers {
+		if c == customerID {
+			ids = append(ids, uid)
+		}
+	}
+	return ids, nil
+}
+
+func TestAdminOrders(t *testing.T) {
+	srv := newTestServerOn(t, &memOrders{customers: map[string]string{"order-1": "cust-1", "order-2": "cust-1"}}, nil)
+
+	for _, tc := range []struct {
+		name, method, path, key string
+		status                  int
+		code                    string
+	}{
+		{"anonymous delete", http.MethodDelete, "/orders/order-1", "", http.StatusUnauthorized, codeUnauthorized},
+		{"support delete", http.MethodDelete, "/orders/order-1", "support", http.StatusForbidden, codeForbidden},
+		{"admin delete", http.MethodDelete, "/orders/order-1", "admin", http.StatusNoContent, ""},
+		{"delete again", http.MethodDelete, "/orders/order-1", "admin", http.StatusNotFound, codeOrderNotFound},
+		{"support erase", http.MethodPost, "/customers/cust-1/erase", "support", http.StatusForbidden, codeForbidden},
+		{"invalid customer", http.MethodPost, "/customers/cust%3B1/erase", "admin", http.StatusBadRequest, codeInvalidOrderID},
+	} {
+		resp, err := do(t, tc.method, srv.URL+tc.path, tc.key)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var p problem
+		json.NewDecoder(resp.Body).Decode(&p)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status || p.Code != tc.code {
+			t.Fatalf("%s: got %d %q, want %d %q", tc.name, resp.StatusCode, p.Code, tc.status, tc.code)
+		}
+	}
+
+	resp, err := do(t, http.MethodPost, srv.URL+"/customers/cust-1/erase", "admin")
+	if err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	var erased struct {
+		CustomerID     string `json:"customer_id"`
+		OrdersAffected int    `json:"orders_affected"`
+	}
+	json.NewDecoder(resp.Body).Decode(&erased)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || erased.CustomerID != "cust-1" || erased.OrdersAffected != 1 {
+		t.Fatalf("erase: %d %+v", resp.StatusCode, erased)
+	}
+}
+
+// memWebhooks — хранилище подписок в памяти.
+type memWebhooks struct {
+	hooks []db.Webhook
+}
+
+func (m *memWebhooks) CreateWebhook(ctx context.Context, url, secret string, events []string) (db.Webhook, error) {
+	w := db.Webhook{ID: int64(len(m.hooks) + 1), URL: url, Secret: secret, Events: events, CreatedAt: time.Now()}
+	m.hooks = append(m.hooks, w)
+	return w, nil
+}
+
+func (m *memWebhooks) ListWebhooks(ctx context.Context) ([]db.Webhook, error) {
+	return m.hooks, nil
+}
+
+func (m *memWebhooks) DeleteWebhook(ctx context.Context, id int64) (bool, error) {
+	for i, w := range m.hooks {
+		if w.ID == id {
+			m.hooks = append(m.hooks[:i], m.hooks[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memWebhooks) WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]db.WebhookDelivery, bool, error) {
+	for _, w := range m.hooks {
+		if w.ID == webhookID {
+			return nil, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func TestWebhooks(t *testing.T) {
+	srv := newTestServerWith(t, func(cfg *Config) { cfg.Webhooks = webhook.NewService(&memWebhooks{}) })
+
+	post := func(body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/admin/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(auth.APIKeyHeader, "admin")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		return resp
+	}
+
+	for body, want := range map[string]int{
+		`{"url":"https://203.0.113.10/hooks","events":["paid"]}`: http.StatusBadRequest, // по спецификации
+		`{"url":"mailto:ops@partner.example"}`:                   http.StatusBadRequest, // по webhook.ErrInvalid
+	} {
+		resp := post(body)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d, want %d", body, resp.StatusCode, want)
+		}
+	}
+
+	resp := post(`{"url":"https://203.0.113.10/hooks","events":["created"]}`)
+	var created struct {
+		ID     int64    `json:"id"`
+		Secret string   `json:"secret"`
+		Events []string `json:"events"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.Secret == "" || len(created.Events) != 1 {
+		t.Fatalf("create: %d %+v", resp.StatusCode, created)
+	}
+
+	// В списке секрета нет.
+	resp, err := do(t, http.MethodGet, srv.URL+"/admin/webhooks", "admin")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "203.0.113.10") || strings.Contains(string(body), created.Secret) {
+		t.Fatalf("list: %d %s", resp.StatusCode, body)
+	}
+
+	id := strconv.FormatInt(created.ID, 10)
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/admin/webhooks/" + id + "/deliveries?limit=10", http.StatusOK},
+		{http.MethodGet, "/admin/webhooks/" + id + "/deliveries?limit=0", http.StatusBadRequest},
+		{http.MethodDelete, "/admin/webhooks/" + id, http.StatusNoContent},
+		{http.MethodDelete, "/admin/webhooks/" + id, http.StatusNotFound},
+		{http.MethodGet, "/admin/webhooks/" + id + "/deliveries", http.StatusNotFound},
+		{http.MethodDelete, "/admin/webhooks/abc", http.StatusBadRequest},
+	} {
+		resp, err := do(t, tc.method, srv.URL+tc.path, "admin")
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.method, tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, resp.StatusCode, tc.want)
+		}
+	}
+
+	resp, err = do(t, http.MethodGet, srv.URL+"/admin/webhooks", "support")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("support: status %d, want 403", resp.StatusCode)
+	}
+}
@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unmatchedRoute — метка маршрута для запросов, не подошедших ни к одному шаблону.
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "l0_http_requests_total",
+		Help: "HTTP requests by route pattern and status code.",
+	}, []string{"route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "l0_http_request_duration_seconds",
+		Help:    "HTTP request latency by route pattern and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "status"})
+)
+
+var tracer = otel.Tracer("L0/internal/httpapi")
+
+// instrument считает запросы и их длительность, пишет журнал запросов и открывает серверный спан,
+// продолжающий трассу клиента из заголовка traceparent. Маршрут берётся из шаблона
+// ServeMux, а не из пути, чтобы число меток не росло с числом заказов.
+// Стоит снаружи остальных middleware и учитывает их ответы (400, 429 и т.д.).
+func instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := unmatchedRoute
+		if _, pattern := mux.Handler(r); pattern != "" {
+			route = pattern
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		code := sw.statusCode()
+		span.SetAttributes(attribute.Int("http.response.status_code", code))
+		if code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(code))
+		}
+
+		elapsed := time.Since(start)
+		status := strconv.Itoa(code)
+		httpRequests.WithLabelValues(route, status).Inc()
+		httpDuration.WithLabelValues(route, status).Observe(elapsed.Seconds())
+		// Путь не логируется: в нём бывает customer_id.
+		slog.InfoContext(ctx, "http request", "method", r.Method, "route", route, "status", code, "duration_ms", elapsed.Milliseconds())
+	})
+}
+
+// statusWriter запоминает код ответа.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap даёт http.ResponseController и websocket доступ к Flush и Hijack.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,144 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"L0/internal/auth"
+	"L0/internal/cache"
+	"L0/internal/compress"
+	"L0/internal/dto"
+	"L0/internal/negotiate"
+	"L0/internal/service"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Форматы ответа с заказом; первый — формат по умолчанию.
+const (
+	mediaJSON     = "application/json"
+	mediaProtobuf = "application/x-protobuf"
+	mediaMsgpack  = "application/msgpack"
+)
+
+// orderMediaTypes перечисляет форматы в порядке предпочтения сервера.
+var orderMediaTypes = []string{mediaJSON, mediaProtobuf, "application/protobuf", mediaMsgpack, "application/x-msgpack"}
+
+// getOrderHandler обрабатывает GET /orders/{id}.
+func getOrderHandler(orders *service.OrderService, ps service.Profiles, cacheControl string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profile, ok := profileFor(ps, w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		entry, err := orders.GetByID(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if entry, err = orders.RedactedFor(id, entry, profile); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		writeOrder(w, r, entry, cacheControl)
+	}
+}
+
+// findOrdersHandler обрабатывает GET /orders?email=...&phone=... — поиск заказов по контактам покупателя.
+func findOrdersHandler(orders *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		ids, err := orders.FindOrders(r.Context(), q.Get("email"), q.Get("phone"))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if ids == nil {
+			ids = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"order_uids": ids})
+	}
+}
+
+// writeOrder отдаёт заказ с учётом Accept, Accept-Encoding, ?pretty=1 и условных заголовков.
+func writeOrder(w http.ResponseWriter, r *http.Request, entry cache.Entry, cacheControl string) {
+	// Ответ зависит от профиля маскирования клиента, поэтому и от его учётных данных.
+	w.Header().Add("Vary", "Accept, Accept-Encoding, Authorization, "+auth.APIKeyHeader)
+	mediaType := negotiate.ContentType(r.Header.Get("Accept"), orderMediaTypes...)
+	if mediaType == "" {
+		writeProblem(w, r, http.StatusNotAcceptable, codeNotAcceptable, "supported types: "+strings.Join(orderMediaTypes, ", "))
+		return
+	}
+
+	data, etag, encoded, err := representation(entry, mediaType, r.URL.Query().Get("pretty"))
+	if err != nil {
+		writeError(w, r, fmt.Errorf("encode order as %s: %w", mediaType, err))
+		return
+	}
+
+	if enc := negotiate.Encoding(r.Header.Get("Accept-Encoding"), compress.Supported...); enc != "" {
+		compressed, ok := encoded[enc]
+		if !ok {
+			if compressed, err = compress.Encode(enc, data); err != nil {
+				slog.WarnContext(r.Context(), "compress order", "encoding", enc, "err", err)
+			}
+		}
+		if compressed != nil {
+			data, etag = compressed, service.VariantETag(etag, enc)
+			w.Header().Set("Content-Encoding", enc)
+		}
+	}
+
+	w.Header().Set("Content-Type", mediaType) // Настраивает заголовок ответа.
+	w.Header().Set("ETag", etag)
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	// ServeContent сам отвечает 304 на If-None-Match / If-Modified-Since и выставляет Last-Modified.
+	http.ServeContent(w, r, "", entry.ModTime, bytes.NewReader(data))
+}
+
+// representation кодирует закэшированный JSON в запрошенный формат и возвращает
+// байты, ETag этого представления и готовые сжатые копии, если они есть в кэше.
+func representation(entry cache.Entry, mediaType, pretty string) ([]byte, string, map[string][]byte, error) {
+	switch mediaType {
+	case mediaJSON:
+		if pretty != "1" && pretty != "true" {
+			return entry.Data, entry.ETag, entry.Encoded, nil
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, entry.Data, "", "  "); err != nil {
+			return nil, "", nil, err
+		}
+		return buf.Bytes(), service.VariantETag(entry.ETag, "pretty"), nil, nil
+	}
+
+	var order dto.Order
+	if err := json.Unmarshal(entry.Data, &order); err != nil {
+		return nil, "", nil, err
+	}
+
+	switch mediaType {
+	case mediaProtobuf, "application/protobuf":
+		data, err := proto.Marshal(dto.ToProto(order))
+		return data, service.VariantETag(entry.ETag, "proto"), nil, err
+	case mediaMsgpack, "application/x-msgpack":
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json") // имена полей совпадают с JSON
+		if err := enc.Encode(order); err != nil {
+			return nil, "", nil, err
+		}
+		return buf.Bytes(), service.VariantETag(entry.ETag, "msgpack"), nil, nil
+	default:
+		return nil, "", nil, fmt.Errorf("unsupported media type %q", mediaType)
+	}
+}
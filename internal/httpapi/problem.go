@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"L0/internal/requestid"
+	"L0/internal/service"
+	"L0/internal/webhook"
+)
+
+// Стабильные машинно-читаемые коды ошибок API.
+const (
+	codeOrderNotFound        = "order_not_found"
+	codeInvalidOrderID       = "invalid_order_id"
+	codeValidationFailed     = "validation_failed"
+	codeInvalidPatch         = "invalid_patch"
+	codePreconditionFailed   = "precondition_failed"
+	codeStorageUnavailable   = "storage_unavailable"
+	codeInternal             = "internal_error"
+	codeInvalidRequest       = "invalid_request"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotAcceptable        = "not_acceptable"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeBodyTooLarge         = "body_too_large"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeRateLimited          = "rate_limited"
+)
+
+// problem — тело ошибки по RFC 7807 (application/problem+json) с расширениями code и request_id.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeProblem отвечает ошибкой в формате problem+json.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Del("Content-Encoding")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{
+		Type:      "urn:l0:problem:" + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestid.FromContext(r.Context()),
+	})
+}
+
+// writeError переводит доменную ошибку сервиса в problem+json. Неизвестные ошибки
+// и ошибки БД логируются, а клиент получает только код без подробностей.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		writeProblem(w, r, http.StatusNotFound, codeOrderNotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidID):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidOrderID, err.Error())
+	case errors.Is(err, service.ErrInvalidPatch):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPatch, err.Error())
+	case errors.Is(err, service.ErrValidationFailed):
+		writeProblem(w, r, http.StatusUnprocessableEntity, codeValidationFailed, err.Error())
+	case errors.Is(err, service.ErrPreconditionFailed):
+		writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, err.Error())
+	case errors.Is(err, webhook.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, webhook.ErrInvalid):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidRequest, err.Error())
+	case errors.Is(err, service.ErrStorageUnavailable):
+		slog.ErrorContext(r.Context(), "storage unavailable", "route", r.Pattern, "err", err)
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, r, http.StatusServiceUnavailable, codeStorageUnavailable, "storage unavailable")
+	default:
+		slog.ErrorContext(r.Context(), "request failed", "route", r.Pattern, "err", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "")
+	}
+}
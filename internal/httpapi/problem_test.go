@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"L0/internal/requestid"
+	"L0/internal/service"
+)
+
+func TestWriteErrorMapping(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{service.ErrOrderNotFound, http.StatusNotFound, codeOrderNotFound},
+		{service.ErrInvalidID, http.StatusBadRequest, codeInvalidOrderID},
+		{fmt.Errorf("%w: missing order_uid", service.ErrValidationFailed), http.StatusUnprocessableEntity, codeValidationFailed},
+		{service.ErrInvalidPatch, http.StatusBadRequest, codeInvalidPatch},
+		{service.ErrPreconditionFailed, http.StatusPreconditionFailed, codePreconditionFailed},
+		{fmt.Errorf("%w: %w", service.ErrStorageUnavailable, errors.New("dial tcp: refused")), http.StatusServiceUnavailable, codeStorageUnavailable},
+		{errors.New("boom"), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		req = req.WithContext(requestid.WithID(req.Context(), "req-1"))
+		rec := httptest.NewRecorder()
+
+		writeError(rec, req, tc.err)
+
+		if rec.Code != tc.status {
+			t.Fatalf("%v: status %d, want %d", tc.err, rec.Code, tc.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Fatalf("%v: content type %q", tc.err, ct)
+		}
+		var p problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%v: invalid body: %v", tc.err, err)
+		}
+		if p.Code != tc.code || p.Status != tc.status || p.RequestID != "req-1" || p.Instance != "/orders/1" {
+			t.Fatalf("%v: unexpected problem %+v", tc.err, p)
+		}
+	}
+}
+
+func TestWriteErrorHidesStorageDetails(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	rec := httptest.NewRecorder()
+
+	writeError(rec, req, fmt.Errorf("%w: %w", service.ErrStorageUnavailable, errors.New("password authentication failed")))
+
+	var p problem
+	json.Unmarshal(rec.Body.Bytes(), &p)
+	if p.Detail != "storage unavailable" {
+		t.Fatalf("storage error leaked to client: %q", p.Detail)
+	}
+}
@@ -0,0 +1,41 @@
+package httpapi
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"L0/internal/ratelimit"
+)
+
+// rateLimit ограничивает частоту запросов каждого клиента к маршруту. Ответ
+// содержит заголовки RateLimit-Limit/Remaining/Reset, а сверх лимита — 429 с Retry-After.
+func rateLimit(l *ratelimit.Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := l.Allow(clientKey(r))
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.Remain))
+		h.Set("RateLimit-Reset", strconv.Itoa(int(d.Reset.Seconds())))
+		if !d.Allowed {
+			h.Set("Retry-After", strconv.Itoa(int(d.Retry.Seconds())))
+			writeProblem(w, r, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey определяет клиента для лимита: по проверенному API-ключу или JWT, а
+// без них — по IP. Непроверенные учётные данные в ключ не идут, иначе каждый
+// поддельный ключ получал бы свою корзину.
+func clientKey(r *http.Request) string {
+	if p, ok := verifiedPrincipal(r); ok {
+		return "sub:" + p.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
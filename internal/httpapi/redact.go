@@ -0,0 +1,19 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"L0/internal/auth"
+	"L0/internal/dto"
+	"L0/internal/service"
+)
+
+// profileFor определяет профиль маскирования для запроса. Если профиль клиента не
+// настроен, отвечает 403: отдать такому клиенту заказ целиком было бы утечкой.
+func profileFor(ps service.Profiles, w http.ResponseWriter, r *http.Request) (*dto.Profile, bool) {
+	profile, ok := ps.For(auth.FromContext(r.Context()))
+	if !ok {
+		writeProblem(w, r, http.StatusForbidden, codeForbidden, "unknown redaction profile")
+	}
+	return profile, ok
+}
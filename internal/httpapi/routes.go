@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"L0/internal/auth"
+	"L0/internal/openapi"
+	"L0/internal/service"
+)
+
+// route — эндпоинт API: шаблон ServeMux вида "METHOD /path", роль, без которой
+// запрос отклоняется (пусто — эндпоинт публичный), и обработчик.
+// Каждый маршрут должен быть описан в internal/openapi/openapi.json.
+type route struct {
+	pattern string
+	role    auth.Role
+	handler http.HandlerFunc
+}
+
+// apiRoutes перечисляет все эндпоинты API, кроме статики, и роль, необходимую для каждого.
+func apiRoutes(orders *service.OrderService, cfg Config) []route {
+	const (
+		reader  = auth.RoleReader
+		support = auth.RoleSupport
+		admin   = auth.RoleAdmin
+	)
+
+	return []route{
+		// Обработчик отдаёт заказ из кэша, а при промахе подгружает из БД.
+		{"GET /orders/{id}", reader, getOrderHandler(orders, cfg.Profiles, cfg.OrderCacheControl)},
+
+		// Поиск по email и телефону раскрывает связь контактов с заказами, поэтому доступен поддержке.
+		{"GET /orders", support, findOrdersHandler(orders)},
+
+		// Лента новых заказов для дашбордов: SSE и WebSocket, фильтры в query-параметрах.
+		{"GET /orders/stream", reader, streamOrdersHandler(orders, cfg.Profiles)},
+		{"GET /orders/ws", reader, wsOrdersHandler(orders, cfg.Profiles)},
+
+		// Изменение заказа доступно поддержке, удаление и стирание персональных данных — только администратору.
+		{"PATCH /orders/{id}", support, patchOrderHandler(orders)},
+		{"DELETE /orders/{id}", admin, deleteOrderHandler(orders)},
+		{"POST /customers/{id}/erase", admin, eraseCustomerHandler(orders)},
+		{"GET /admin/nats/lag", admin, natsLagHandler(cfg.Lag)},
+
+		// Подписки партнёров на события о заказах.
+		{"POST /admin/webhooks", admin, createWebhookHandler(cfg.Webhooks)},
+		{"GET /admin/webhooks", admin, listWebhooksHandler(cfg.Webhooks)},
+		{"DELETE /admin/webhooks/{id}", admin, deleteWebhookHandler(cfg.Webhooks)},
+		{"GET /admin/webhooks/{id}/deliveries", admin, webhookDeliveriesHandler(cfg.Webhooks)},
+
+		{"GET " + openapi.Path, "", openapi.Handler()},
+
+		// Пробы оркестратора доступны без учётных данных.
+		{"GET /healthz", "", healthzHandler()},
+		{"GET /readyz", "", readyzHandler(cfg.Health)},
+		{"GET /health", "", healthHandler(cfg.Health)},
+	}
+}
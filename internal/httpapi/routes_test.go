@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"L0/internal/openapi"
+)
+
+// TestRoutesMatchOpenAPI падает, если набор маршрутов и спецификация разошлись.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	for _, rt := range apiRoutes(nil, Config{}) {
+		method, path, ok := strings.Cut(rt.pattern, " ")
+		if !ok {
+			t.Fatalf("route %q must include a method", rt.pattern)
+		}
+		registered[method+" "+path] = true
+	}
+
+	var missing, undocumented []string
+	for op := range documented {
+		if !registered[op] {
+			missing = append(missing, op)
+		}
+	}
+	for op := range registered {
+		if !documented[op] {
+			undocumented = append(undocumented, op)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(undocumented)
+
+	if len(missing) > 0 {
+		t.Errorf("described in openapi.json but not registered: %v", missing)
+	}
+	if len(undocumented) > 0 {
+		t.Errorf("registered but not described in openapi.json: %v", undocumented)
+	}
+}
+
+// TestRoutesRegister проверяет, что шаблоны маршрутов не конфликтуют в ServeMux.
+func TestRoutesRegister(t *testing.T) {
+	mux := http.NewServeMux()
+	for _, rt := range apiRoutes(nil, Config{}) {
+		mux.Handle(rt.pattern, rt.handler)
+	}
+	mux.Handle("/", http.NotFoundHandler())
+}
@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"L0/internal/service"
+
+	"github.com/coder/websocket"
+)
+
+const (
+	// streamBuffer — сколько событий копится для медленного клиента ленты;
+	// при переполнении новые события для него отбрасываются.
+	streamBuffer = 64
+	// streamHeartbeat — период пустых сообщений, не дающих прокси закрыть соединение.
+	streamHeartbeat = 15 * time.Second
+	// wsWriteTimeout ограничивает отправку одного сообщения по WebSocket.
+	wsWriteTimeout = 5 * time.Second
+)
+
+// streamFilter собирает фильтр ленты из параметров запроса.
+func streamFilter(q url.Values) service.Filter {
+	return service.Filter{
+		DeliveryService: q.Get("delivery_service"),
+		Locale:          q.Get("locale"),
+		CustomerID:      q.Get("customer_id"),
+	}
+}
+
+// streamOrdersHandler обрабатывает GET /orders/stream — ленту заказов через Server-Sent Events.
+func streamOrdersHandler(orders *service.OrderService, ps service.Profiles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profile, ok := profileFor(ps, w, r)
+		if !ok {
+			return
+		}
+		rc := http.NewResponseController(w)
+		// Лента живёт дольше WriteTimeout сервера, поэтому снимаем дедлайн для этого запроса.
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			writeError(w, r, fmt.Errorf("streaming unsupported: %w", err))
+			return
+		}
+
+		events := orders.Events()
+		sub := events.Subscribe(streamBuffer, streamFilter(r.URL.Query()))
+		defer events.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // отключает буферизацию в nginx
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		rc.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		var reported uint64
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case ev, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				// Сообщаем клиенту, что часть событий потеряна из-за медленного чтения.
+				if dropped := sub.Dropped(); dropped != reported {
+					fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped)
+					reported = dropped
+				}
+				entry, err := orders.RedactedFor(ev.OrderUID, ev.Entry, profile)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "redact stream event", "order_uid", ev.OrderUID, "err", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: order\nid: %s\ndata: %s\n\n", ev.OrderUID, entry.Data)
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsOrdersHandler обрабатывает GET /orders/ws — ту же ленту через WebSocket.
+// Каждое сообщение — нормализованный JSON заказа.
+func wsOrdersHandler(orders *service.OrderService, ps service.Profiles) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profile, ok := profileFor(ps, w, r)
+		if !ok {
+			return
+		}
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return // Accept уже ответил клиенту
+		}
+		defer conn.CloseNow()
+
+		events := orders.Events()
+		sub := events.Subscribe(streamBuffer, streamFilter(r.URL.Query()))
+		defer events.Unsubscribe(sub)
+
+		// Лента односторонняя: CloseRead обрабатывает управляющие кадры и
+		// отменяет ctx, когда клиент закрывает соединение.
+		ctx := conn.CloseRead(r.Context())
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				pingCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+				err := conn.Ping(pingCtx)
+				cancel()
+				if err != nil {
+					return
+				}
+			case ev, ok := <-sub.C:
+				if !ok {
+					conn.Close(websocket.StatusGoingAway, "server is shutting down")
+					return
+				}
+				entry, err := orders.RedactedFor(ev.OrderUID, ev.Entry, profile)
+				if err != nil {
+					slog.ErrorContext(ctx, "redact stream event", "order_uid", ev.OrderUID, "err", err)
+					continue
+				}
+				writeCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+				err = conn.Write(writeCtx, websocket.MessageText, entry.Data)
+				cancel()
+				if err != nil {
+					slog.DebugContext(ctx, "websocket write", "err", err)
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"L0/internal/auth"
+	"L0/internal/cache"
+	"L0/internal/service"
+
+	"github.com/coder/websocket"
+)
+
+// publishUntil публикует событие о заказе order-1, пока не закрыт done: клиент
+// мог ещё не успеть подписаться на ленту.
+func publishUntil(orders *service.OrderService, done <-chan struct{}) {
+	ev := service.Event{
+		OrderUID:        "order-1",
+		DeliveryService: "meest",
+		Entry:           cache.Entry{Data: []byte(cachedOrder), ETag: `"v1"`},
+	}
+	for {
+		orders.Events().Publish(ev)
+		select {
+		case <-done:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestStreamOrdersSSE(t *testing.T) {
+	orders := service.NewOrderService(nil, cache.New())
+	srv := serveOrders(t, orders, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/orders/stream?delivery_service=meest", nil)
+	req.Header.Set(auth.APIKeyHeader, "reader")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go publishUntil(orders, done)
+
+	var event, data string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() && data == "" {
+		line := sc.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok && event == "order" {
+			data = v
+		}
+	}
+	if data == "" {
+		t.Fatalf("no order event received: %v", sc.Err())
+	}
+	// Лента маскируется профилем клиента так же, как GET /orders/{id}.
+	if !strings.Contains(data, `"order_uid":"order-1"`) || strings.Contains(data, "+9720000000") {
+		t.Fatalf("unexpected event data: %s", data)
+	}
+}
+
+func TestStreamOrdersWebSocket(t *testing.T) {
+	orders := service.NewOrderService(nil, cache.New())
+	srv := serveOrders(t, orders, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/orders/ws"
+	if _, _, err := websocket.Dial(ctx, url, nil); err == nil {
+		t.Fatal("websocket without credentials must be rejected")
+	}
+
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{
+		HTTPHeader: http.Header{auth.APIKeyHeader: {"reader"}},
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	done := make(chan struct{})
+	defer close(done)
+	go publishUntil(orders, done)
+
+	typ, data, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if typ != websocket.MessageText || !strings.Contains(string(data), `"order_uid":"order-1"`) || strings.Contains(string(data), "+9720000000") {
+		t.Fatalf("unexpected message %v: %s", typ, data)
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+}
@@ -0,0 +1,121 @@
+// Package logging настраивает log/slog: JSON в stderr, уровень из конфигурации и
+// атрибуты из контекста (request_id, трасса, order_uid и т.п.) в каждой записи.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"L0/internal/requestid"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redacted подставляется вместо значений атрибутов с персональными данными.
+const redacted = "[redacted]"
+
+// piiPaths — полные пути атрибутов (группы через точку), значения которых не
+// попадают в лог. Путь, а не ключ: "name" подписки или профиля — не персональные
+// данные, а "delivery.name" — да. Заказ целиком логировать нельзя — только order_uid.
+var piiPaths = map[string]bool{
+	"phone":            true,
+	"email":            true,
+	"customer_id":      true,
+	"delivery.name":    true,
+	"delivery.phone":   true,
+	"delivery.email":   true,
+	"delivery.address": true,
+}
+
+// ParseLevel разбирает уровень debug, info, warn или error; пустая строка — info.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+// New создаёт JSON-логгер, который добавляет атрибуты из контекста и скрывает персональные данные.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: replacePII})
+	return slog.New(contextHandler{h})
+}
+
+// Setup делает JSON-логгер с уровнем level логгером по умолчанию; стандартный
+// пакет log тоже пишет через него.
+func Setup(level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(New(os.Stderr, l))
+	return nil
+}
+
+// Fatal пишет ошибку и завершает процесс, как log.Fatal.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type ctxKey struct{}
+
+// With возвращает контекст, записи с которым (slog.InfoContext и т.п.) получат
+// атрибуты args в дополнение к уже добавленным.
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	attrs := append(prev[:len(prev):len(prev)], argsToAttrs(args)...)
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// contextHandler дополняет записи request_id, trace_id/span_id и атрибутами из With.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func replacePII(groups []string, a slog.Attr) slog.Attr {
+	if piiPaths[strings.Join(append(groups[:len(groups):len(groups)], a.Key), ".")] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
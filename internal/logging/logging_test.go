@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"L0/internal/requestid"
+)
+
+func TestContextAttrsAndPII(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	ctx := requestid.WithID(context.Background(), "req-1")
+	ctx = With(ctx, "nats_seq", uint64(42), "redelivered", true)
+	ctx = With(ctx, "order_uid", "order-1")
+	logger.WarnContext(ctx, "skip message", "email", "test@gmail.com", "name", "partner-hook",
+		slog.Group("delivery", "phone", "+9720000000", "name", "Test Testov", "city", "Kiryat Mozkin"))
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":         "skip message",
+		"level":       "WARN",
+		"request_id":  "req-1",
+		"nats_seq":    float64(42),
+		"redelivered": true,
+		"order_uid":   "order-1",
+		"email":       redacted,
+		"name":        "partner-hook", // не персональные данные: путь не delivery.name
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Fatalf("%s = %v, want %v (record %s)", k, rec[k], v, buf.String())
+		}
+	}
+	delivery := rec["delivery"].(map[string]any)
+	if delivery["phone"] != redacted || delivery["name"] != redacted || delivery["city"] != "Kiryat Mozkin" {
+		t.Fatalf("unexpected delivery group: %v", delivery)
+	}
+
+	buf.Reset()
+	logger.DebugContext(ctx, "hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("debug record written at info level: %s", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"": slog.LevelInfo, "debug": slog.LevelDebug, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
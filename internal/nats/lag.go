@@ -0,0 +1,182 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"L0/internal/health"
+)
+
+// channelsPath — эндпоинт мониторинга NATS Streaming со сведениями о канале.
+const channelsPath = "/streaming/channelsz"
+
+// ErrLagUnknown — отставание ещё не измерено или мониторинг недоступен.
+var ErrLagUnknown = errors.New("nats: consumer lag unknown")
+
+// Lag — отставание подписки от канала на момент последнего опроса мониторинга.
+type Lag struct {
+	Channel string `json:"channel"`
+	// LastSequence — последнее сообщение в канале, ProcessedSequence — последнее обработанное сервисом.
+	LastSequence      uint64    `json:"last_sequence"`
+	ProcessedSequence uint64    `json:"processed_sequence"`
+	Lag               uint64    `json:"lag"`
+	Threshold         uint64    `json:"threshold"`
+	Degraded          bool      `json:"degraded"`
+	CheckedAt         time.Time `json:"checked_at"`
+	// Error — почему не удался последний опрос; числа остаются от предыдущего.
+	Error string `json:"error,omitempty"`
+}
+
+// channelz — нужная часть ответа /streaming/channelsz?channel=...&subs=1.
+type channelz struct {
+	LastSeq       uint64 `json:"last_seq"`
+	Subscriptions []struct {
+		ClientID     string `json:"client_id"`
+		DurableName  string `json:"durable_name"`
+		LastSent     uint64 `json:"last_sent"`
+		PendingCount int    `json:"pending_count"`
+	} `json:"subscriptions"`
+}
+
+// LagMonitor опрашивает мониторинг NATS Streaming (порт 8222) и сравнивает
+// последнюю последовательность канала с обработанной подпиской.
+type LagMonitor struct {
+	url       string
+	threshold uint64
+	client    *http.Client
+
+	mu  sync.RWMutex
+	lag Lag
+}
+
+// NewLagMonitor создаёт монитор; при отставании больше threshold сообщений
+// сервис считается degraded.
+func NewLagMonitor(monitorURL string, threshold uint64) *LagMonitor {
+	return &LagMonitor{
+		url:       strings.TrimRight(monitorURL, "/"),
+		threshold: threshold,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Run измеряет отставание сразу и затем раз в interval, пока ctx не отменён.
+func (m *LagMonitor) Run(ctx context.Context, c *Consumer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Poll(ctx, c)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll один раз измеряет отставание c, обновляет метрики и результат для Lag.
+func (m *LagMonitor) Poll(ctx context.Context, c *Consumer) (Lag, error) {
+	channel := c.cfg.Channel
+	ch, err := m.channel(ctx, channel)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		lagPollErrors.Inc()
+		m.lag.Channel, m.lag.Threshold = channel, m.threshold
+		m.lag.Degraded = true
+		m.lag.CheckedAt = time.Now()
+		m.lag.Error = err.Error()
+		lagDegraded.WithLabelValues(channel).Set(1)
+		return m.lag, err
+	}
+
+	processed := c.LastSequence()
+	// Пока после запуска не пришло ни одного сообщения, позицию durable-подписки
+	// знает только сервер: последнее отправленное минус неподтверждённые.
+	for _, sub := range ch.Subscriptions {
+		if sub.ClientID == c.cfg.ClientID && sub.DurableName == c.cfg.DurableName {
+			processed = max(processed, sub.LastSent-min(uint64(sub.PendingCount), sub.LastSent))
+		}
+	}
+
+	lag := Lag{
+		Channel:           channel,
+		LastSequence:      ch.LastSeq,
+		ProcessedSequence: processed,
+		Threshold:         m.threshold,
+		CheckedAt:         time.Now(),
+	}
+	if ch.LastSeq > processed {
+		lag.Lag = ch.LastSeq - processed
+	}
+	lag.Degraded = lag.Lag > m.threshold
+	m.lag = lag
+
+	channelLastSeq.WithLabelValues(channel).Set(float64(lag.LastSequence))
+	processedSeq.WithLabelValues(channel).Set(float64(lag.ProcessedSequence))
+	consumerLag.WithLabelValues(channel).Set(float64(lag.Lag))
+	if lag.Degraded {
+		lagDegraded.WithLabelValues(channel).Set(1)
+	} else {
+		lagDegraded.WithLabelValues(channel).Set(0)
+	}
+	return lag, nil
+}
+
+// channel запрашивает состояние канала и его подписок.
+func (m *LagMonitor) channel(ctx context.Context, name string) (channelz, error) {
+	u := m.url + channelsPath + "?subs=1&channel=" + url.QueryEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return channelz{}, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return channelz{}, fmt.Errorf("nats monitoring: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// Канал создаётся первой подпиской или публикацией: пока его нет, отставать не от чего.
+		return channelz{}, nil
+	default:
+		return channelz{}, fmt.Errorf("nats monitoring: %s", resp.Status)
+	}
+
+	var ch channelz
+	if err := json.NewDecoder(resp.Body).Decode(&ch); err != nil {
+		return channelz{}, fmt.Errorf("nats monitoring: decode channelz: %w", err)
+	}
+	return ch, nil
+}
+
+// Lag возвращает результат последнего опроса.
+func (m *LagMonitor) Lag() Lag {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lag
+}
+
+// Check — проверка для health.Checker: при отставании выше порога или
+// недоступном мониторинге сервис degraded, но остаётся готовым.
+func (m *LagMonitor) Check(context.Context) error {
+	lag := m.Lag()
+	switch {
+	case lag.CheckedAt.IsZero():
+		return fmt.Errorf("%w: %w", health.ErrDegraded, ErrLagUnknown)
+	case lag.Error != "":
+		return fmt.Errorf("%w: %w: %s", health.ErrDegraded, ErrLagUnknown, lag.Error)
+	case lag.Degraded:
+		return fmt.Errorf("%w: %s lag %d exceeds %d", health.ErrDegraded, lag.Channel, lag.Lag, lag.Threshold)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"L0/internal/health"
+
+	stan "github.com/nats-io/stan.go"
+)
+
+func TestLagMonitor(t *testing.T) {
+	s := runServer(t, -1)
+	monitoring := httptest.NewServer(http.HandlerFunc(s.HandleChannelsz))
+	defer monitoring.Close()
+
+	release := make(chan struct{})
+	c, err := Subscribe(testConfig(s, "svc-1"), func(ctx context.Context, msg *stan.Msg) error {
+		if msg.Sequence > 3 {
+			<-release // БД тормозит, очередь копится
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer c.Close()
+
+	m := NewLagMonitor(monitoring.URL, 2)
+	if err := m.Check(context.Background()); !errors.Is(err, health.ErrDegraded) || !errors.Is(err, ErrLagUnknown) {
+		t.Fatalf("check before first poll: %v", err)
+	}
+
+	publish(t, s, "1", "2", "3")
+	waitFor(t, "processed 3", func() bool { return c.LastSequence() == 3 })
+	lag, err := m.Poll(context.Background(), c)
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if lag.LastSequence != 3 || lag.ProcessedSequence != 3 || lag.Lag != 0 || lag.Degraded {
+		t.Fatalf("caught up: %+v", lag)
+	}
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatalf("check caught up: %v", err)
+	}
+
+	publish(t, s, "4", "5", "6", "7")
+	lag, err = m.Poll(context.Background(), c)
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if lag.LastSequence != 7 || lag.Lag != 4 || !lag.Degraded {
+		t.Fatalf("behind: %+v", lag)
+	}
+	if err := m.Check(context.Background()); !errors.Is(err, health.ErrDegraded) {
+		t.Fatalf("check behind: %v", err)
+	}
+
+	close(release)
+	waitFor(t, "processed 7", func() bool { return c.LastSequence() == 7 })
+	if lag, _ := m.Poll(context.Background(), c); lag.Lag != 0 || lag.Degraded {
+		t.Fatalf("after catch-up: %+v", lag)
+	}
+
+	// Без мониторинга отставание неизвестно: числа остаются прежними, сервис degraded.
+	monitoring.Close()
+	lag, err = m.Poll(context.Background(), c)
+	if err == nil || !lag.Degraded || lag.LastSequence != 7 {
+		t.Fatalf("monitoring down: %+v, %v", lag, err)
+	}
+	if err := m.Check(context.Background()); !errors.Is(err, ErrLagUnknown) {
+		t.Fatalf("check monitoring down: %v", err)
+	}
+}
+
+func TestLagGrowsWhenHandlerFails(t *testing.T) {
+	s := runServer(t, -1)
+	monitoring := httptest.NewServer(http.HandlerFunc(s.HandleChannelsz))
+	defer monitoring.Close()
+
+	failed := make(chan uint64, 100)
+	c, err := Subscribe(testConfig(s, "svc-1"), func(ctx context.Context, msg *stan.Msg) error {
+		if msg.Sequence > 2 {
+			failed <- msg.Sequence
+			return errors.New("storage unavailable") // БД недоступна, сообщение не подтверждается
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer c.Close()
+	m := NewLagMonitor(monitoring.URL, 2)
+
+	publish(t, s, "1", "2")
+	waitFor(t, "processed 2", func() bool { return c.LastSequence() == 2 })
+
+	// Обработчик падает, сообщения не подтверждаются: обработанная позиция стоит,
+	// а отставание растёт с каждым новым сообщением.
+	for _, step := range []struct {
+		publish []string
+		lag     uint64
+	}{
+		{[]string{"3"}, 1},
+		{[]string{"4", "5"}, 3},
+	} {
+		publish(t, s, step.publish...)
+		waitFor(t, "failed delivery", func() bool { return len(failed) > 0 })
+		lag, err := m.Poll(context.Background(), c)
+		if err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+		if c.LastSequence() != 2 || lag.ProcessedSequence != 2 || lag.Lag != step.lag || lag.Degraded != (step.lag > 2) {
+			t.Fatalf("after failures: processed %d, %+v", c.LastSequence(), lag)
+		}
+		for len(failed) > 0 {
+			<-failed
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package nats
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// connectionsLost и reconnects считают потери STAN-соединения и попытки его восстановить
+var (
+	connectionsLost = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "l0_nats_connection_lost_total",
+		Help: "NATS Streaming connections lost (ping failure or client replaced on the server).",
+	})
+	reconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "l0_nats_reconnects_total",
+		Help: "Attempts to reconnect and resubscribe to NATS Streaming, by result (ok or failed).",
+	}, []string{"result"})
+)
+
+// Collector отдаёт состояние подписки как метрику l0_nats_connected (1 — подключена)
+func (c *Consumer) Collector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "l0_nats_connected",
+		Help: "Whether the NATS Streaming subscription is connected (1) or not (0).",
+	}, func() float64 {
+		if c.Check(context.Background()) != nil {
+			return 0
+		}
+		return 1
+	})
+}
+
+// Отставание подписки по данным LagMonitor
+var (
+	channelLastSeq = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "l0_nats_channel_last_sequence",
+		Help: "Last sequence in the NATS Streaming channel, from the monitoring endpoint.",
+	}, []string{"channel"})
+	processedSeq = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "l0_nats_processed_sequence",
+		Help: "Last channel sequence processed by the service.",
+	}, []string{"channel"})
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "l0_nats_consumer_lag",
+		Help: "Messages in the channel not yet processed by the service.",
+	}, []string{"channel"})
+	lagDegraded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "l0_nats_consumer_degraded",
+		Help: "Whether the consumer lag exceeds the threshold or is unknown (1) or not (0).",
+	}, []string{"channel"})
+	lagPollErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "l0_nats_lag_poll_errors_total",
+		Help: "Failed requests to the NATS Streaming monitoring endpoint.",
+	})
+)
@@ -1,29 +1,313 @@
 package nats
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	natsgo "github.com/nats-io/nats.go"
 	stan "github.com/nats-io/stan.go"
 )
 
-// Подключается к NATS Streaming и возвращает соединение и подписку
-func Subscribe(clusterID, clientID, channel string, handler func(msg *stan.Msg)) (stan.Conn, stan.Subscription, error) {
-	sc, err := stan.Connect(clusterID, clientID, stan.NatsURL("nats://localhost:4222"))
+// ErrDisconnected возвращает Check, если соединение с NATS потеряно.
+var ErrDisconnected = errors.New("nats: not connected")
+
+// errClosed — переподключение прервано вызовом Close.
+var errClosed = errors.New("nats: consumer closed")
+
+// Паузы между попытками переподключения, если они не заданы в Config.
+const (
+	defaultReconnectWait    = time.Second
+	defaultMaxReconnectWait = 30 * time.Second
+)
+
+// Config — параметры подключения к NATS Streaming и durable-подписки.
+type Config struct {
+	URL         string
+	ClusterID   string
+	ClientID    string
+	Channel     string
+	DurableName string
+	// MaxInflight — сколько неподтверждённых сообщений сервер отдаёт подписчику.
+	MaxInflight int
+	// AckWait — через сколько неподтверждённое сообщение доставляется повторно.
+	AckWait time.Duration
+	// PingInterval и PingMaxOut: соединение считается потерянным, если сервер не
+	// ответил на PingMaxOut пингов подряд, отправляемых раз в PingInterval
+	// (не меньше секунды). Ноль — значения stan.go по умолчанию.
+	PingInterval time.Duration
+	PingMaxOut   int
+	// ReconnectWait — пауза перед первой попыткой переподключения; после каждой
+	// неудачи она удваивается до MaxReconnectWait.
+	ReconnectWait    time.Duration
+	MaxReconnectWait time.Duration
+}
+
+// Handler обрабатывает сообщение. Если он вернул nil, Consumer подтверждает
+// сообщение; при ошибке оно остаётся неподтверждённым, и сервер доставит его
+// повторно через AckWait. ctx не отменяется по сигналу остановки — только если
+// Drain не дождался обработчика.
+type Handler func(ctx context.Context, msg *stan.Msg) error
+
+// Consumer — durable-подписка на канал, которая сама переподключается после
+// потери соединения и умеет дождаться обработки уже полученных сообщений перед остановкой.
+type Consumer struct {
+	cfg     Config
+	handler Handler
+
+	ctx    context.Context // контекст обработчиков
+	cancel context.CancelFunc
+	done   chan struct{} // закрывается в Close и останавливает переподключение
+
+	mu        sync.Mutex
+	sc        stan.Conn
+	sub       stan.Subscription
+	connected bool // false с потери соединения до успешной переподписки
+	draining  bool
+	closed    bool
+	inflight  sync.WaitGroup
+
+	processed atomic.Uint64 // наибольшая последовательность, обработанная и подтверждённая
+}
+
+// Subscribe подключается к NATS Streaming и создаёт durable-подписку с ручным
+// подтверждением: новые подписчики получают все сообщения канала, а после
+// перезапуска подписка продолжается с первого неподтверждённого.
+// Если соединение потеряно, Consumer переподключается в фоне с тем же client ID
+// и durable-именем, поэтому подписка продолжается с той же позиции.
+func Subscribe(cfg Config, handler Handler) (*Consumer, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Consumer{cfg: cfg, handler: handler, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	if err := c.connect(); err != nil {
+		cancel()
+		return nil, err
+	}
+	slog.Info("subscribed", "channel", cfg.Channel, "durable", cfg.DurableName)
+	return c, nil
+}
+
+// connect устанавливает соединение, создаёт подписку и делает их текущими.
+func (c *Consumer) connect() error {
+	connOpts := []stan.Option{
+		stan.NatsURL(c.cfg.URL),
+		stan.SetConnectionLostHandler(c.connectionLost),
+		// Разрыв TCP сам по себе не теряет подписку: nats.go переподключается,
+		// а STAN-соединение живо, пока сервер отвечает на пинги.
+		stan.NatsOptions(
+			natsgo.DisconnectErrHandler(func(_ *natsgo.Conn, err error) {
+				slog.Warn("nats disconnected", "err", err)
+			}),
+			natsgo.ReconnectHandler(func(nc *natsgo.Conn) {
+				slog.Info("nats reconnected", "url", nc.ConnectedUrlRedacted())
+			}),
+		),
+	}
+	if c.cfg.PingInterval > 0 || c.cfg.PingMaxOut > 0 {
+		interval := max(int(c.cfg.PingInterval/time.Second), 1)
+		maxOut := c.cfg.PingMaxOut
+		if maxOut == 0 {
+			maxOut = stan.DefaultPingMaxOut
+		}
+		connOpts = append(connOpts, stan.Pings(interval, maxOut))
+	}
+	sc, err := stan.Connect(c.cfg.ClusterID, c.cfg.ClientID, connOpts...)
 	if err != nil {
-		return nil, nil, err
+		return fmt.Errorf("nats connect: %w", err)
 	}
 
-	sub, err := sc.Subscribe(
-		channel,
-		handler,
+	subOpts := []stan.SubscriptionOption{
 		stan.DeliverAllAvailable(),
-		stan.DurableName("orders-svc"),
-		stan.MaxInflight(25),
-	)
+		stan.DurableName(c.cfg.DurableName),
+		stan.SetManualAckMode(),
+	}
+	if c.cfg.MaxInflight > 0 {
+		subOpts = append(subOpts, stan.MaxInflight(c.cfg.MaxInflight))
+	}
+	if c.cfg.AckWait > 0 {
+		subOpts = append(subOpts, stan.AckWait(c.cfg.AckWait))
+	}
+	sub, err := sc.Subscribe(c.cfg.Channel, c.handle, subOpts...)
 	if err != nil {
-		return nil, nil, err
+		sc.Close()
+		return fmt.Errorf("nats subscribe %s: %w", c.cfg.Channel, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		// Close вызван, пока шло переподключение: durable-позицию сохраняем, соединение закрываем.
+		sub.Close()
+		sc.Close()
+		return errClosed
+	}
+	c.sc, c.sub, c.connected = sc, sub, true
+	return nil
+}
+
+// connectionLost вызывается stan.go, когда сервер перестал отвечать на пинги или
+// забыл клиента (например, перезапустился без сохранённого состояния).
+func (c *Consumer) connectionLost(_ stan.Conn, err error) {
+	c.mu.Lock()
+	c.connected = false
+	closed := c.closed
+	c.mu.Unlock()
+
+	connectionsLost.Inc()
+	slog.Error("nats connection lost", "err", err)
+	if !closed {
+		c.reconnect()
+	}
+}
+
+// reconnect переподключается с экспоненциальной паузой, пока не получится или
+// пока Consumer не закрыт.
+func (c *Consumer) reconnect() {
+	wait := c.cfg.ReconnectWait
+	if wait <= 0 {
+		wait = defaultReconnectWait
 	}
+	maxWait := c.cfg.MaxReconnectWait
+	if maxWait <= 0 {
+		maxWait = defaultMaxReconnectWait
+	}
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(wait):
+		}
+
+		err := c.connect()
+		if errors.Is(err, errClosed) {
+			return
+		}
+		if err == nil {
+			reconnects.WithLabelValues("ok").Inc()
+			slog.Info("nats resubscribed", "channel", c.cfg.Channel, "durable", c.cfg.DurableName, "attempt", attempt)
+			return
+		}
+		reconnects.WithLabelValues("failed").Inc()
+		slog.Warn("nats reconnect failed", "attempt", attempt, "retry_in", wait, "err", err)
+		wait = min(wait*2, maxWait)
+	}
+}
+
+func (c *Consumer) handle(msg *stan.Msg) {
+	c.mu.Lock()
+	if c.draining {
+		// Сообщение не подтверждается и после перезапуска придёт снова.
+		c.mu.Unlock()
+		return
+	}
+	c.inflight.Add(1)
+	c.mu.Unlock()
+	defer c.inflight.Done()
+
+	if err := c.handler(c.ctx, msg); err != nil {
+		return
+	}
+	if err := msg.Ack(); err != nil {
+		slog.Error("nats ack", "nats_seq", msg.Sequence, "err", err)
+		return
+	}
+	for {
+		last := c.processed.Load()
+		if msg.Sequence <= last || c.processed.CompareAndSwap(last, msg.Sequence) {
+			break
+		}
+	}
+}
+
+// LastSequence возвращает наибольшую последовательность канала, обработанную и
+// подтверждённую с запуска сервиса; 0 — ещё ни одного сообщения.
+func (c *Consumer) LastSequence() uint64 {
+	return c.processed.Load()
+}
+
+// Drain перестаёт обрабатывать новые сообщения и ждёт завершения начатых.
+// Если ctx истёк раньше, контекст обработчиков отменяется, и Drain всё равно
+// дожидается их выхода, чтобы после него хранилище можно было закрыть; их
+// сообщения остаются неподтверждёнными и будут доставлены повторно.
+func (c *Consumer) Drain(ctx context.Context) error {
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
 
-	log.Println("Subscribed to", channel)
-	return sc, sub, nil
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		// Подтверждения уходят асинхронно: дожидаемся, пока сервер их получит.
+		c.mu.Lock()
+		sc, connected := c.sc, c.connected
+		c.mu.Unlock()
+		if !connected {
+			return ErrDisconnected
+		}
+		if nc := sc.NatsConn(); nc != nil {
+			return nc.FlushWithContext(ctx)
+		}
+		return nil
+	case <-ctx.Done():
+		c.cancel()
+		<-done
+		return fmt.Errorf("nats drain: %w", ctx.Err())
+	}
+}
+
+// Close останавливает переподключение, закрывает подписку, сохраняя
+// durable-позицию на сервере, и соединение.
+func (c *Consumer) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	sc, sub, connected := c.sc, c.sub, c.connected
+	c.mu.Unlock()
+
+	close(c.done)
+	c.cancel()
+	if !connected {
+		// Потерянное соединение stan.go уже закрыл.
+		return nil
+	}
+	err := sub.Close()
+	return errors.Join(err, sc.Close())
+}
+
+// Publish синхронно публикует data в subject через текущее соединение: ошибки
+// нет, только если сервер подтвердил сохранение сообщения.
+func (c *Consumer) Publish(subject string, data []byte) error {
+	c.mu.Lock()
+	sc, connected := c.sc, c.connected
+	c.mu.Unlock()
+	if !connected {
+		return ErrDisconnected
+	}
+	return sc.Publish(subject, data)
+}
+
+// Check проверяет, что соединение с NATS Streaming установлено и подписка активна.
+func (c *Consumer) Check(context.Context) error {
+	c.mu.Lock()
+	sc, connected := c.sc, c.connected
+	c.mu.Unlock()
+	if !connected {
+		return ErrDisconnected
+	}
+	if nc := sc.NatsConn(); nc == nil || !nc.IsConnected() {
+		return ErrDisconnected
+	}
+	return nil
 }
@@ -0,0 +1,228 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	stand "github.com/nats-io/nats-streaming-server/server"
+	stan "github.com/nats-io/stan.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const testCluster = "test-cluster"
+
+// runServer поднимает встроенный NATS Streaming с хранилищем в памяти;
+// port -1 — любой свободный порт.
+func runServer(t *testing.T, port int) *stand.StanServer {
+	t.Helper()
+	opts := stand.GetDefaultOptions()
+	opts.ID = testCluster
+	nopts := stand.DefaultNatsServerOptions
+	nopts.Port = port
+	s, err := stand.RunServerWithOpts(opts, &nopts)
+	if err != nil {
+		t.Fatalf("run nats streaming: %v", err)
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func testConfig(s *stand.StanServer, clientID string) Config {
+	return Config{
+		URL:         s.ClientURL(),
+		ClusterID:   testCluster,
+		ClientID:    clientID,
+		Channel:     "orders",
+		DurableName: "orders-svc",
+		AckWait:     time.Second,
+	}
+}
+
+func publish(t *testing.T, s *stand.StanServer, data ...string) {
+	t.Helper()
+	sc, err := stan.Connect(testCluster, "publisher", stan.NatsURL(s.ClientURL()))
+	if err != nil {
+		t.Fatalf("connect publisher: %v", err)
+	}
+	defer sc.Close()
+	for _, d := range data {
+		if err := sc.Publish("orders", []byte(d)); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+}
+
+func TestConsumerDrainWaitsForInflight(t *testing.T) {
+	s := runServer(t, -1)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handled := make(chan string, 10)
+	c, err := Subscribe(testConfig(s, "svc-1"), func(ctx context.Context, msg *stan.Msg) error {
+		if string(msg.Data) == "first" {
+			close(started)
+			<-release // заказ ещё пишется в БД
+		}
+		handled <- string(msg.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	publish(t, s, "first")
+	<-started
+
+	drained := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		drained <- c.Drain(ctx)
+	}()
+	publish(t, s, "second") // пришло во время остановки — не обрабатывается
+
+	select {
+	case err := <-drained:
+		t.Fatalf("drain returned before in-flight message finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := <-handled; got != "first" {
+		t.Fatalf("handled %q, want first", got)
+	}
+	select {
+	case got := <-handled:
+		t.Fatalf("message %q handled after drain started", got)
+	default:
+	}
+
+	// После перезапуска durable-подписка (ключ — client ID и имя) продолжается
+	// с неподтверждённого сообщения.
+	redelivered := make(chan *stan.Msg, 10)
+	c, err = Subscribe(testConfig(s, "svc-1"), func(ctx context.Context, msg *stan.Msg) error {
+		redelivered <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("resubscribe: %v", err)
+	}
+	defer c.Close()
+	select {
+	case msg := <-redelivered:
+		if string(msg.Data) != "second" {
+			t.Fatalf("resumed with %q, want second", msg.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unacked message was not redelivered")
+	}
+}
+
+func TestConsumerDrainDeadline(t *testing.T) {
+	s := runServer(t, -1)
+
+	cancelled := make(chan struct{})
+	started := make(chan struct{})
+	c, err := Subscribe(testConfig(s, "svc-1"), func(ctx context.Context, msg *stan.Msg) error {
+		close(started)
+		<-ctx.Done() // транзакция прерывается, сообщение не подтверждается
+		close(cancelled)
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer c.Close()
+
+	publish(t, s, "stuck")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	// Drain возвращается только после выхода обработчика.
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("drain returned before the cancelled handler finished")
+	}
+}
+
+func TestConsumerResubscribesAfterServerRestart(t *testing.T) {
+	// Порт фиксирован, чтобы перезапущенный сервер был доступен по тому же адресу.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	s := runServer(t, port)
+	cfg := testConfig(s, "svc-1")
+	cfg.PingInterval = time.Second
+	cfg.PingMaxOut = 2
+	cfg.ReconnectWait = 50 * time.Millisecond
+
+	handled := make(chan string, 10)
+	c, err := Subscribe(cfg, func(ctx context.Context, msg *stan.Msg) error {
+		handled <- string(msg.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer c.Close()
+	lostBefore := testutil.ToFloat64(connectionsLost)
+
+	publish(t, s, "before")
+	if got := <-handled; got != "before" {
+		t.Fatalf("handled %q, want before", got)
+	}
+
+	// Сервер перезапускается с пустым хранилищем и не знает ни клиента, ни подписки.
+	s.Shutdown()
+	waitFor(t, "disconnected", func() bool { return c.Check(context.Background()) != nil })
+	s = runServer(t, port)
+
+	// nats.go переподключается сам, но сервер отвечает на пинг, что клиента нет.
+	waitFor(t, "connection lost", func() bool { return testutil.ToFloat64(connectionsLost) > lostBefore })
+	waitFor(t, "resubscribed", func() bool { return c.Check(context.Background()) == nil })
+	if got := testutil.ToFloat64(c.Collector()); got != 1 {
+		t.Fatalf("l0_nats_connected = %v, want 1", got)
+	}
+
+	publish(t, s, "after")
+	select {
+	case got := <-handled:
+		if got != "after" {
+			t.Fatalf("handled %q, want after", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message published after restart was not delivered")
+	}
+}
+
+// waitFor ждёт выполнения условия до 15 секунд.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
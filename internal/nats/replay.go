@@ -0,0 +1,139 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	stan "github.com/nats-io/stan.go"
+)
+
+// defaultIdleTimeout — сколько Replay ждёт следующего сообщения, если IdleTimeout не задан.
+const defaultIdleTimeout = 10 * time.Second
+
+// ErrNoLastMessage — за IdleTimeout не пришло последнее сообщение канала: канал пуст
+// или сервер не отвечает, и понять, докуда повторять, нельзя.
+var ErrNoLastMessage = errors.New("nats: last message of the channel not received")
+
+// ReplayOptions задаёт, с какого места повторить канал.
+type ReplayOptions struct {
+	// StartSequence — первая повторяемая последовательность; 0 — начинать со StartTime.
+	StartSequence uint64
+	StartTime     time.Time
+	// IdleTimeout — сколько ждать следующего сообщения, прежде чем считать канал прочитанным.
+	IdleTimeout time.Duration
+}
+
+// Replay подключается к NATS Streaming клиентом cfg.ClientID (он должен отличаться
+// от клиента сервиса) и открывает временную подписку на cfg.Channel с позиции opts.
+// Сообщения передаются handler по одному, пока не будет обработано последнее
+// сообщение канала на момент запуска, не истечёт IdleTimeout или ctx.
+// Durable-подписка сервиса не затрагивается. Возвращает число обработанных сообщений.
+func Replay(ctx context.Context, cfg Config, opts ReplayOptions, handler func(ctx context.Context, msg *stan.Msg)) (int, error) {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	var start stan.SubscriptionOption
+	switch {
+	case opts.StartSequence > 0:
+		start = stan.StartAtSequence(opts.StartSequence)
+	case !opts.StartTime.IsZero():
+		start = stan.StartAtTime(opts.StartTime)
+	default:
+		return 0, fmt.Errorf("nats replay: start sequence or time is required")
+	}
+
+	sc, err := stan.Connect(cfg.ClusterID, cfg.ClientID, stan.NatsURL(cfg.URL))
+	if err != nil {
+		return 0, fmt.Errorf("nats connect: %w", err)
+	}
+	defer sc.Close()
+
+	last, err := lastSequence(ctx, sc, cfg.Channel, opts.IdleTimeout)
+	if err != nil || opts.StartSequence > last {
+		return 0, err
+	}
+
+	var (
+		mu       sync.Mutex
+		stopped  bool
+		count    int
+		activity = make(chan struct{}, 1)
+		done     = make(chan struct{})
+	)
+	subOpts := []stan.SubscriptionOption{start}
+	if cfg.MaxInflight > 0 {
+		subOpts = append(subOpts, stan.MaxInflight(cfg.MaxInflight))
+	}
+	sub, err := sc.Subscribe(cfg.Channel, func(msg *stan.Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		handler(ctx, msg)
+		count++
+		select {
+		case activity <- struct{}{}:
+		default:
+		}
+		if msg.Sequence >= last {
+			stopped = true
+			close(done)
+		}
+	}, subOpts...)
+	if err != nil {
+		return 0, fmt.Errorf("nats subscribe %s: %w", cfg.Channel, err)
+	}
+	defer sub.Unsubscribe()
+
+	idle := time.NewTimer(opts.IdleTimeout)
+	defer idle.Stop()
+wait:
+	for {
+		select {
+		case <-done:
+			return count, nil
+		case <-activity:
+			idle.Reset(opts.IdleTimeout)
+		case <-idle.C:
+			break wait
+		case <-ctx.Done():
+			err = ctx.Err()
+			break wait
+		}
+	}
+
+	// Дожидаемся обработчика, который сейчас выполняется, и не пускаем следующие.
+	mu.Lock()
+	defer mu.Unlock()
+	stopped = true
+	return count, err
+}
+
+// lastSequence возвращает последнюю последовательность канала или ErrNoLastMessage,
+// если за timeout она не пришла.
+func lastSequence(ctx context.Context, sc stan.Conn, channel string, timeout time.Duration) (uint64, error) {
+	got := make(chan uint64, 1)
+	sub, err := sc.Subscribe(channel, func(msg *stan.Msg) {
+		select {
+		case got <- msg.Sequence:
+		default:
+		}
+	}, stan.StartWithLastReceived())
+	if err != nil {
+		return 0, fmt.Errorf("nats subscribe %s: %w", channel, err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case seq := <-got:
+		return seq, nil
+	case <-time.After(timeout):
+		return 0, fmt.Errorf("%w on %s within %s", ErrNoLastMessage, channel, timeout)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	stan "github.com/nats-io/stan.go"
+)
+
+func TestReplay(t *testing.T) {
+	s := runServer(t, -1)
+	publish(t, s, "1", "2", "3", "4", "5")
+	cfg := testConfig(s, "replay")
+	opts := ReplayOptions{IdleTimeout: 500 * time.Millisecond}
+
+	replay := func(opts ReplayOptions) []string {
+		t.Helper()
+		var got []string
+		n, err := Replay(context.Background(), cfg, opts, func(ctx context.Context, msg *stan.Msg) {
+			got = append(got, string(msg.Data))
+		})
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		if n != len(got) {
+			t.Fatalf("replay returned %d, handled %d", n, len(got))
+		}
+		return got
+	}
+
+	opts.StartSequence = 3
+	if got := replay(opts); !slices.Equal(got, []string{"3", "4", "5"}) {
+		t.Fatalf("from sequence 3: %v", got)
+	}
+
+	opts.StartSequence = 0
+	opts.StartTime = time.Now().Add(-time.Minute)
+	if got := replay(opts); !slices.Equal(got, []string{"1", "2", "3", "4", "5"}) {
+		t.Fatalf("from time: %v", got)
+	}
+
+	// Позиция за концом канала: повторять нечего, Replay не ждёт новых сообщений.
+	opts = ReplayOptions{StartSequence: 10, IdleTimeout: time.Minute}
+	if got := replay(opts); len(got) != 0 {
+		t.Fatalf("past the end: %v", got)
+	}
+}
+
+func TestReplayWithoutLastMessage(t *testing.T) {
+	s := runServer(t, -1)
+	cfg := testConfig(s, "replay")
+
+	// Канал пуст: последнее сообщение не приходит, и Replay не должен молча вернуть 0.
+	n, err := Replay(context.Background(), cfg, ReplayOptions{StartSequence: 1, IdleTimeout: 200 * time.Millisecond},
+		func(context.Context, *stan.Msg) { t.Error("unexpected message") })
+	if !errors.Is(err, ErrNoLastMessage) || n != 0 {
+		t.Fatalf("expected ErrNoLastMessage, got %d, %v", n, err)
+	}
+}
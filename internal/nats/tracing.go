@@ -0,0 +1,32 @@
+package nats
+
+import (
+	"context"
+	"strconv"
+
+	"L0/internal/envelope"
+	"L0/internal/tracing"
+
+	stan "github.com/nats-io/stan.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("L0/internal/nats")
+
+// StartSpan начинает спан обработки сообщения STAN. Если паблишер положил
+// контекст трассировки в заголовки конверта, спан продолжает его трассу.
+func StartSpan(ctx context.Context, channel string, msg *stan.Msg) (context.Context, trace.Span) {
+	ctx = tracing.Extract(ctx, envelope.Unwrap(msg.Data).Headers)
+	return tracer.Start(ctx, channel+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats_streaming"),
+			attribute.String("messaging.destination.name", channel),
+			attribute.String("messaging.message.id", strconv.FormatUint(msg.Sequence, 10)),
+			attribute.Bool("messaging.nats_streaming.redelivered", msg.Redelivered),
+			attribute.Int("messaging.message.body.size", len(msg.Data)),
+		),
+	)
+}
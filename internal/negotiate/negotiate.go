@@ -0,0 +1,111 @@
+package negotiate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// spec — один элемент заголовка Accept / Accept-Encoding с его весом q.
+type spec struct {
+	value string
+	q     float64
+}
+
+// parse разбирает список вида "a;q=0.5, b" в элементы с весами.
+func parse(header string) []spec {
+	var specs []spec
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				q = parsed
+			}
+		}
+		specs = append(specs, spec{value: value, q: q})
+	}
+	return specs
+}
+
+// Encoding выбирает Content-Encoding по заголовку Accept-Encoding.
+// offers перечислены в порядке предпочтения сервера; при равных весах побеждает
+// более ранний. Пустая строка означает identity (без сжатия).
+func Encoding(acceptEncoding string, offers ...string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	specs := parse(acceptEncoding)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, found := 0.0, false
+		for _, s := range specs {
+			if s.value == offer {
+				q, found = s.q, true
+				break
+			}
+		}
+		if !found {
+			for _, s := range specs {
+				if s.value == "*" {
+					q = s.q
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// ContentType выбирает тип ответа по заголовку Accept. offers перечислены
+// в порядке предпочтения сервера. Пустой Accept означает первый из offers,
+// пустой результат — ни один тип не подходит (406).
+func ContentType(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	specs := parse(accept)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		offerType, _, _ := strings.Cut(offer, "/")
+
+		// Берём вес самого специфичного подходящего диапазона.
+		q, specificity := 0.0, -1
+		for _, s := range specs {
+			rangeType, rangeSub, _ := strings.Cut(s.value, "/")
+			var level int
+			switch {
+			case s.value == offer:
+				level = 2
+			case rangeType == offerType && rangeSub == "*":
+				level = 1
+			case s.value == "*/*":
+				level = 0
+			default:
+				continue
+			}
+			if level > specificity {
+				q, specificity = s.q, level
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
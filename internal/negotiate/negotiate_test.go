@@ -0,0 +1,50 @@
+package negotiate
+
+import "testing"
+
+func TestEncoding(t *testing.T) {
+	offers := []string{"zstd", "gzip"}
+
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.5", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0.1, zstd;q=0", "gzip"},
+		{"identity", ""},
+		{"gzip;q=0", ""},
+		{"br", ""},
+	}
+	for _, tc := range cases {
+		if got := Encoding(tc.header, offers...); got != tc.want {
+			t.Fatalf("Encoding(%q) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	offers := []string{"application/json", "application/x-protobuf"}
+
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/*", "application/json"},
+		{"application/x-protobuf", "application/x-protobuf"},
+		{"application/json;q=0.5, application/x-protobuf", "application/x-protobuf"},
+		{"application/*;q=0.2, application/json;q=0", "application/x-protobuf"},
+		{"text/html, */*;q=0.1", "application/json"},
+		{"text/html", ""},
+	}
+	for _, tc := range cases {
+		if got := ContentType(tc.header, offers...); got != tc.want {
+			t.Fatalf("ContentType(%q) = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// Path — адрес, по которому сервис отдаёт спецификацию.
+const Path = "/openapi.json"
+
+//go:embed openapi.json
+var spec []byte
+
+// Load разбирает встроенную спецификацию и проверяет её корректность.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Handler отдаёт спецификацию как есть.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// Validator возвращает middleware, который проверяет параметры и тело запроса
+// по спецификации. Запросы к путям вне спецификации (статика) и к неописанным
+// методам пропускаются дальше — на них ответит сам ServeMux.
+// Аутентификацию middleware не проверяет: это делают обработчики.
+// Ответ на некорректный запрос формирует onError.
+func Validator(doc *openapi3.T, onError func(w http.ResponseWriter, r *http.Request, err error)) (func(http.Handler) http.Handler, error) {
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	opts := &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := router.FindRoute(r)
+			var routeErr *routers.RouteError
+			if errors.As(err, &routeErr) { // путь или метод не описан в спецификации
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err != nil {
+				onError(w, r, err)
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    opts,
+			}
+			if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				onError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
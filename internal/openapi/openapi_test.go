@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	if doc.Components.Schemas["Order"] == nil {
+		t.Fatal("expected Order schema")
+	}
+}
+
+func TestValidator(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	validate, err := Validator(doc, badRequest)
+	if err != nil {
+		t.Fatalf("validator: %v", err)
+	}
+	h := validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot) // запрос дошёл до обработчика
+	}))
+
+	cases := []struct {
+		name        string
+		method, url string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"valid get", http.MethodGet, "/orders/b563feb7b2b84b6test", "", "", http.StatusTeapot},
+		{"valid pretty", http.MethodGet, "/orders/b563feb7b2b84b6test?pretty=1", "", "", http.StatusTeapot},
+		{"invalid pretty", http.MethodGet, "/orders/b563feb7b2b84b6test?pretty=yes", "", "", http.StatusBadRequest},
+		{"stream", http.MethodGet, "/orders/stream?locale=en", "", "", http.StatusTeapot},
+		{"valid patch", http.MethodPatch, "/orders/1", "application/merge-patch+json", `{"delivery":{"city":"Haifa"}}`, http.StatusTeapot},
+		{"patch array", http.MethodPatch, "/orders/1", "application/merge-patch+json", `["x"]`, http.StatusBadRequest},
+		{"patch without body", http.MethodPatch, "/orders/1", "application/merge-patch+json", "", http.StatusBadRequest},
+		{"static file", http.MethodGet, "/index.html", "", "", http.StatusTeapot},
+		{"unknown method", http.MethodPut, "/orders/1", "", "", http.StatusTeapot},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidatorKeepsBody(t *testing.T) {
+	doc, _ := Load()
+	validate, _ := Validator(doc, badRequest)
+
+	body := `{"delivery":{"city":"Haifa"}}`
+	h := validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err := io.ReadAll(r.Body)
+		if err != nil || string(got) != body {
+			t.Errorf("handler got body %q, err %v", got, err)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodPatch, "/orders/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func badRequest(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
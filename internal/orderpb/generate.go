@@ -0,0 +1,4 @@
+// Package orderpb содержит сгенерированные из proto/order/v1 типы.
+package orderpb
+
+//go:generate sh -c "cd ../.. && buf generate"
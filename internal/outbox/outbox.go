@@ -0,0 +1,123 @@
+// Package outbox публикует события о заказах из таблицы order_events в NATS:
+// событие пишется в транзакции изменения заказа, а Relay доставляет его
+// «хотя бы один раз» и отмечает отправленным.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"L0/internal/db"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	published = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "l0_outbox_events_published_total",
+		Help: "Order events published from the outbox, by type (created, updated, deleted).",
+	}, []string{"type"})
+	relayErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "l0_outbox_relay_errors_total",
+		Help: "Failed outbox relay rounds (database or publish error).",
+	})
+)
+
+// pruneInterval — как часто из outbox удаляются отправленные события старше срока хранения.
+const pruneInterval = time.Hour
+
+// pruneBatch — сколько событий удаляется одним запросом.
+const pruneBatch = 1000
+
+// Store — хранилище outbox; его реализует *db.DB.
+type Store interface {
+	RelayEvents(ctx context.Context, limit int, publish func(db.OrderEvent) error) (int, error)
+	PruneEvents(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Publisher публикует сообщение и возвращает nil, только когда брокер его сохранил.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Relay периодически переносит неотправленные события из Store в subject.
+type Relay struct {
+	store     Store
+	pub       Publisher
+	subject   string
+	interval  time.Duration
+	batch     int
+	retention time.Duration
+}
+
+// New создаёт Relay, который раз в interval отправляет события пачками до batch штук.
+// Отправленные события хранятся retention; 0 — бессрочно.
+func New(store Store, pub Publisher, subject string, interval time.Duration, batch int, retention time.Duration) *Relay {
+	return &Relay{store: store, pub: pub, subject: subject, interval: interval, batch: batch, retention: retention}
+}
+
+// Run отправляет события, пока ctx не отменён. Полная пачка означает, что в
+// outbox остались события, и следующая берётся без паузы.
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var pruned time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if r.retention > 0 && time.Since(pruned) >= pruneInterval {
+			pruned = time.Now()
+			if n, err := r.Prune(ctx); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "outbox cleanup failed", "deleted", n, "err", err)
+			}
+		}
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			relayErrors.Inc()
+			slog.WarnContext(ctx, "outbox relay failed", "sent", n, "err", err)
+		}
+		if err == nil && n == r.batch {
+			timer.Reset(0)
+		} else {
+			timer.Reset(r.interval)
+		}
+	}
+}
+
+// RelayOnce отправляет одну пачку событий и возвращает число отправленных.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	return r.store.RelayEvents(ctx, r.batch, func(ev db.OrderEvent) error {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		if err := r.pub.Publish(r.subject, data); err != nil {
+			return fmt.Errorf("publish event %d: %w", ev.ID, err)
+		}
+		published.WithLabelValues(ev.Type).Inc()
+		return nil
+	})
+}
+
+// Prune удаляет отправленные события старше срока хранения и возвращает их число.
+func (r *Relay) Prune(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-r.retention)
+	var total int64
+	for {
+		n, err := r.store.PruneEvents(ctx, before, pruneBatch)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("prune order events: %w", err)
+		}
+		if n < pruneBatch {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"L0/internal/db"
+)
+
+// memStore — outbox в памяти с той же семантикой, что и db.RelayEvents.
+type memStore struct {
+	mu     sync.Mutex
+	events []db.OrderEvent
+	sent   map[int64]bool
+}
+
+func (s *memStore) RelayEvents(ctx context.Context, limit int, publish func(db.OrderEvent) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, ev := range s.events {
+		if s.sent[ev.ID] || n == limit {
+			continue
+		}
+		if err := publish(ev); err != nil {
+			return n, err
+		}
+		s.sent[ev.ID] = true
+		n++
+	}
+	return n, nil
+}
+
+func (s *memStore) PruneEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	s.events = slices.DeleteFunc(s.events, func(ev db.OrderEvent) bool {
+		if !s.sent[ev.ID] || !ev.OccurredAt.Before(before) || n == int64(limit) {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, nil
+}
+
+func (s *memStore) sentCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sent)
+}
+
+type message struct {
+	subject string
+	data    []byte
+}
+
+// flakyPublisher отказывает на событии с id failOn один раз.
+type flakyPublisher struct {
+	failOn int64
+	got    []message
+}
+
+func (p *flakyPublisher) Publish(subject string, data []byte) error {
+	var ev db.OrderEvent
+	json.Unmarshal(data, &ev)
+	if ev.ID == p.failOn {
+		p.failOn = 0
+		return errors.New("nats: timeout")
+	}
+	p.got = append(p.got, message{subject, data})
+	return nil
+}
+
+func TestRelayAtLeastOnce(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	store := &memStore{sent: map[int64]bool{}, events: []db.OrderEvent{
+		{ID: 1, Type: db.EventCreated, OrderUID: "order-1", OccurredAt: now, Order: &db.OrderSummary{TrackNumber: "WB1", Amount: 1817, Items: 1}},
+		{ID: 2, Type: db.EventUpdated, OrderUID: "order-1", OccurredAt: now, Order: &db.OrderSummary{TrackNumber: "WB1", Amount: 1900, Items: 2}},
+		{ID: 3, Type: db.EventDeleted, OrderUID: "order-1", OccurredAt: now},
+	}}
+	pub := &flakyPublisher{failOn: 2}
+	r := New(store, pub, "order_events", time.Second, 10, 0)
+
+	// Публикация второго события не удалась: первое отмечено, второе и третье ждут.
+	if n, err := r.RelayOnce(context.Background()); n != 1 || err == nil {
+		t.Fatalf("first round: %d, %v", n, err)
+	}
+	if n, err := r.RelayOnce(context.Background()); n != 2 || err != nil {
+		t.Fatalf("second round: %d, %v", n, err)
+	}
+	if n, _ := r.RelayOnce(context.Background()); n != 0 {
+		t.Fatalf("nothing left to send, sent %d", n)
+	}
+
+	if len(pub.got) != 3 {
+		t.Fatalf("published %d events, want 3", len(pub.got))
+	}
+	for i, m := range pub.got {
+		var ev db.OrderEvent
+		if err := json.Unmarshal(m.data, &ev); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+		if m.subject != "order_events" || ev.ID != int64(i+1) {
+			t.Fatalf("event %d: subject %q id %d", i, m.subject, ev.ID)
+		}
+	}
+	var deleted map[string]any
+	json.Unmarshal(pub.got[2].data, &deleted)
+	if deleted["type"] != db.EventDeleted || deleted["order"] != nil {
+		t.Fatalf("deleted event: %s", pub.got[2].data)
+	}
+}
+
+func TestRelayRunDrainsBacklog(t *testing.T) {
+	store := &memStore{sent: map[int64]bool{}}
+	for id := int64(1); id <= 5; id++ {
+		store.events = append(store.events, db.OrderEvent{ID: id, Type: db.EventCreated})
+	}
+	pub := &flakyPublisher{}
+	// Пачки по 2 события и пауза в час: весь backlog уходит без ожидания.
+	r := New(store, pub, "order_events", time.Hour, 2, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for store.sentCount() < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sent %d of 5 events", store.sentCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestPrune(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	store := &memStore{sent: map[int64]bool{1: true, 3: true}, events: []db.OrderEvent{
+		{ID: 1, Type: db.EventCreated, OccurredAt: old},
+		{ID: 2, Type: db.EventUpdated, OccurredAt: old}, // ещё не отправлено
+		{ID: 3, Type: db.EventUpdated, OccurredAt: time.Now()},
+	}}
+	r := New(store, &flakyPublisher{}, "order_events", time.Second, 10, 24*time.Hour)
+
+	if n, err := r.Prune(context.Background()); n != 1 || err != nil {
+		t.Fatalf("prune: %d, %v", n, err)
+	}
+	if len(store.events) != 2 || store.events[0].ID != 2 || store.events[1].ID != 3 {
+		t.Fatalf("events left: %+v", store.events)
+	}
+}
@@ -0,0 +1,110 @@
+// Package ratelimit ограничивает частоту запросов каждого клиента алгоритмом
+// token bucket: у клиента есть корзина на Burst запросов, которая пополняется
+// со скоростью Rate запросов в секунду.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+// Limit — скорость пополнения (запросов в секунду) и размер корзины.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// idleTTL — через сколько без запросов корзина клиента забывается; к этому
+// времени она всё равно успевает наполниться.
+const idleTTL = 10 * time.Minute
+
+// requests считает решения лимитеров по маршрутам.
+var requests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "l0_ratelimit_requests_total",
+	Help: "Requests checked by the rate limiter, by route and result (allowed or limited).",
+}, []string{"route", "result"})
+
+// Decision — результат проверки запроса.
+type Decision struct {
+	Allowed bool
+	Limit   int           // размер корзины
+	Remain  int           // сколько запросов осталось без ожидания
+	Reset   time.Duration // через сколько корзина наполнится полностью
+	Retry   time.Duration // через сколько появится следующий запрос, если Allowed == false
+}
+
+type bucket struct {
+	lim  *rate.Limiter
+	seen time.Time
+}
+
+// Limiter хранит корзины клиентов одного маршрута.
+type Limiter struct {
+	route string
+	limit Limit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New создаёт лимитер маршрута route; имя маршрута попадает в метрики.
+func New(route string, limit Limit) *Limiter {
+	return &Limiter{route: route, limit: limit, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Allow списывает запрос клиента key и сообщает, можно ли его выполнить.
+func (l *Limiter) Allow(key string) Decision {
+	now := l.now()
+
+	l.mu.Lock()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{lim: rate.NewLimiter(rate.Limit(l.limit.Rate), l.limit.Burst)}
+		l.buckets[key] = b
+	}
+	b.seen = now
+	allowed := b.lim.AllowN(now, 1)
+	tokens := b.lim.TokensAt(now)
+	l.mu.Unlock()
+
+	d := Decision{Allowed: allowed, Limit: l.limit.Burst, Remain: max(0, int(math.Floor(tokens)))}
+	if l.limit.Rate > 0 {
+		d.Reset = seconds((float64(l.limit.Burst) - tokens) / l.limit.Rate)
+		if !allowed {
+			d.Retry = seconds((1 - tokens) / l.limit.Rate)
+		}
+	}
+
+	result := "allowed"
+	if !allowed {
+		result = "limited"
+	}
+	requests.WithLabelValues(l.route, result).Inc()
+	return d
+}
+
+// sweep удаляет корзины клиентов, не появлявшихся дольше idleTTL. Вызывается под mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTTL {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.seen) > idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// seconds округляет время вверх до целых секунд: заголовки RateLimit-* и Retry-After целочисленные.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(max(0, s))) * time.Second
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New("GET /test", Limit{Rate: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	for i := range 2 {
+		if d := l.Allow("alice"); !d.Allowed || d.Remain != 1-i {
+			t.Fatalf("request %d: %+v", i, d)
+		}
+	}
+	d := l.Allow("alice")
+	if d.Allowed || d.Retry != time.Second || d.Reset != 2*time.Second || d.Limit != 2 {
+		t.Fatalf("expected limited decision, got %+v", d)
+	}
+
+	// Корзины клиентов независимы.
+	if d := l.Allow("bob"); !d.Allowed {
+		t.Fatalf("other client limited: %+v", d)
+	}
+
+	now = now.Add(time.Second)
+	if d := l.Allow("alice"); !d.Allowed {
+		t.Fatalf("bucket must refill, got %+v", d)
+	}
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("GET /test", "limited")); got != 1 {
+		t.Fatalf("limited counter = %v", got)
+	}
+}
+
+func TestLimiterForgetsIdleClients(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New("GET /idle", Limit{Rate: 1, Burst: 1})
+	l.now = func() time.Time { return now }
+
+	l.Allow("alice")
+	now = now.Add(2 * idleTTL)
+	l.Allow("bob")
+
+	if _, ok := l.buckets["alice"]; ok {
+		t.Fatal("idle bucket must be removed")
+	}
+}
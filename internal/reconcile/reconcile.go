@@ -0,0 +1,402 @@
+// Package reconcile сверяет две формы хранения заказа — orders.raw, из которого
+// читают GetOrder и кэш, и нормализованные колонки orders с таблицами deliveries,
+// payments и items — и по запросу чинит одну форму по другой.
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"L0/internal/db"
+	"L0/internal/model"
+)
+
+// Направления починки.
+const (
+	// RepairNormalized перезаписывает колонки и таблицы по raw.
+	RepairNormalized = "normalized"
+	// RepairRaw переносит в raw расходящиеся поля из таблиц; остальной raw, включая
+	// поля вне модели заказа, не меняется.
+	RepairRaw = "raw"
+)
+
+// piiFields — поля, значения которых не попадают в отчёт.
+var piiFields = []string{"delivery.name", "delivery.phone", "delivery.email", "delivery.address"}
+
+// errMissingRow — в нормализованных таблицах нет строки, и собрать по ним raw нельзя.
+var errMissingRow = errors.New("normalized row is missing")
+
+// Store — хранилище заказов; его реализует *db.DB.
+type Store interface {
+	ListOrderIDs(ctx context.Context, after string, limit int) ([]string, error)
+	LoadOrderPair(ctx context.Context, orderUID string) (db.OrderPair, bool, error)
+	RepairOrder(ctx context.Context, orderUID string, fix func(db.OrderPair) (model.Order, json.RawMessage, error)) (bool, error)
+}
+
+// Mismatch — поле, значение которого в raw и в таблицах различается.
+type Mismatch struct {
+	// Field — путь к полю: "payment.amount", "items[1].price"; "items" — разное число
+	// позиций, "delivery" и "payment" — нет строки в таблице.
+	Field      string `json:"field"`
+	Raw        any    `json:"raw"`
+	Normalized any    `json:"normalized"`
+	// Redacted — значения не выводятся, потому что это персональные данные.
+	Redacted bool `json:"redacted,omitempty"`
+
+	path []string
+}
+
+// OrderReport — итог сверки одного заказа.
+type OrderReport struct {
+	OrderUID   string     `json:"order_uid"`
+	Mismatches []Mismatch `json:"mismatches,omitempty"`
+	Repaired   bool       `json:"repaired,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Report — итог сверки; в Orders попадают только заказы с расхождениями или ошибками.
+type Report struct {
+	Repair     string        `json:"repair,omitempty"`
+	Checked    int           `json:"checked"`
+	Mismatched int           `json:"mismatched"`
+	Repaired   int           `json:"repaired"`
+	Failed     int           `json:"failed"`
+	Orders     []OrderReport `json:"orders"`
+	// NotFound — заказы из Options.OrderUIDs, которых нет в БД.
+	NotFound []string `json:"not_found,omitempty"`
+}
+
+// Options задаёт, какие заказы сверять и как чинить.
+type Options struct {
+	// OrderUIDs — сверить только эти заказы; пусто — все по возрастанию order_uid.
+	OrderUIDs []string
+	// Repair — RepairNormalized, RepairRaw или пусто (только отчёт).
+	Repair string
+	// Batch — сколько order_uid читать за раз при обходе всех заказов.
+	Batch int
+}
+
+// Run сверяет заказы и, если задан opts.Repair, чинит расходящиеся. Ошибка
+// отдельного заказа попадает в отчёт; Run прерывается только при ошибке обхода или отмене ctx.
+func Run(ctx context.Context, store Store, opts Options) (Report, error) {
+	if opts.Repair != "" && opts.Repair != RepairNormalized && opts.Repair != RepairRaw {
+		return Report{}, fmt.Errorf("unknown repair direction %q", opts.Repair)
+	}
+	if opts.Batch <= 0 {
+		opts.Batch = 500
+	}
+	rep := Report{Repair: opts.Repair, Orders: []OrderReport{}}
+
+	check := func(uid string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		or, found := reconcileOrder(ctx, store, uid, opts.Repair)
+		if !found {
+			// При обходе всех заказов это значит, что заказ удалили во время обхода.
+			if len(opts.OrderUIDs) > 0 {
+				rep.NotFound = append(rep.NotFound, uid)
+				slog.WarnContext(ctx, "order not found", "order_uid", uid)
+			}
+			return nil
+		}
+		rep.Checked++
+		switch {
+		case or.Error != "":
+			rep.Failed++
+			slog.WarnContext(ctx, "reconcile order failed", "order_uid", uid, "err", or.Error)
+		case or.Repaired:
+			rep.Repaired++
+			slog.InfoContext(ctx, "order repaired", "order_uid", uid, "mismatches", len(or.Mismatches), "repair", opts.Repair)
+		}
+		if len(or.Mismatches) > 0 {
+			rep.Mismatched++
+		}
+		if len(or.Mismatches) > 0 || or.Error != "" {
+			rep.Orders = append(rep.Orders, or)
+		}
+		return nil
+	}
+
+	if len(opts.OrderUIDs) > 0 {
+		for _, uid := range opts.OrderUIDs {
+			if err := check(uid); err != nil {
+				return rep, err
+			}
+		}
+		return rep, nil
+	}
+	for after := ""; ; {
+		ids, err := store.ListOrderIDs(ctx, after, opts.Batch)
+		if err != nil {
+			return rep, fmt.Errorf("list orders: %w", err)
+		}
+		for _, uid := range ids {
+			if err := check(uid); err != nil {
+				return rep, err
+			}
+		}
+		if len(ids) < opts.Batch {
+			return rep, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// reconcileOrder сверяет и при необходимости чинит один заказ; false — заказа нет.
+func reconcileOrder(ctx context.Context, store Store, uid, repair string) (OrderReport, bool) {
+	or := OrderReport{OrderUID: uid}
+	pair, ok, err := store.LoadOrderPair(ctx, uid)
+	if err != nil {
+		or.Error = err.Error()
+		return or, true
+	}
+	if !ok {
+		return or, false
+	}
+	if or.Mismatches, err = Compare(pair); err != nil {
+		or.Error = err.Error()
+		return or, true
+	}
+	if len(or.Mismatches) == 0 || repair == "" {
+		return or, true
+	}
+
+	// Заказ мог измениться после чтения: под блокировкой расхождения считаются заново.
+	_, err = store.RepairOrder(ctx, uid, func(pair db.OrderPair) (model.Order, json.RawMessage, error) {
+		return Fix(pair, repair)
+	})
+	if err != nil {
+		or.Error = fmt.Sprintf("repair %s: %v", repair, err)
+		return or, true
+	}
+	or.Repaired = true
+	return or, true
+}
+
+// Compare возвращает поля, в которых raw и нормализованные таблицы расходятся.
+// Ошибка — raw не разбирается как заказ.
+func Compare(pair db.OrderPair) ([]Mismatch, error) {
+	fromRaw, err := decodeRaw(pair.Raw)
+	if err != nil {
+		return nil, err
+	}
+	norm := pair.Normalized
+	var ms []Mismatch
+	if !pair.HasDelivery {
+		ms = append(ms, Mismatch{Field: "delivery", Normalized: "missing row", path: []string{"delivery"}})
+		norm.Delivery = fromRaw.Delivery
+	}
+	if !pair.HasPayment {
+		ms = append(ms, Mismatch{Field: "payment", Normalized: "missing row", path: []string{"payment"}})
+		norm.Payment = fromRaw.Payment
+	}
+	// Дата хранится в TIMESTAMPTZ: сравниваются моменты времени, а не запись. Если в raw
+	// дата не разбирается, SaveOrder пишет в колонку время сохранения, и сравнивать нечего.
+	if t, err := time.Parse(time.RFC3339, fromRaw.DateCreated); err != nil || t.UTC().Format(time.RFC3339) == norm.DateCreated {
+		norm.DateCreated = fromRaw.DateCreated
+	}
+
+	a, b := toTree(fromRaw), toTree(norm)
+	return append(ms, diff(nil, a, b)...), nil
+}
+
+// Fix возвращает заказ и raw, которые нужно сохранить, чтобы формы pair сошлись
+// в направлении repair; nil raw — расхождений нет.
+func Fix(pair db.OrderPair, repair string) (model.Order, json.RawMessage, error) {
+	ms, err := Compare(pair)
+	if err != nil || len(ms) == 0 {
+		return model.Order{}, nil, err
+	}
+	switch repair {
+	case RepairNormalized:
+		order, err := decodeRaw(pair.Raw)
+		if err != nil {
+			return model.Order{}, nil, err
+		}
+		// Иначе сохранение по raw создало бы другой заказ.
+		if order.OrderUID != pair.OrderUID {
+			return model.Order{}, nil, fmt.Errorf("raw order_uid %q differs from the row key", order.OrderUID)
+		}
+		return order, pair.Raw, nil
+	case RepairRaw:
+		if !pair.HasDelivery || !pair.HasPayment {
+			return model.Order{}, nil, errMissingRow
+		}
+		raw, err := patchRaw(pair.Raw, pair.Normalized, ms)
+		if err != nil {
+			return model.Order{}, nil, err
+		}
+		order, err := decodeRaw(raw)
+		return order, raw, err
+	}
+	return model.Order{}, nil, fmt.Errorf("unknown repair direction %q", repair)
+}
+
+func decodeRaw(raw json.RawMessage) (model.Order, error) {
+	var order model.Order
+	if err := json.Unmarshal(raw, &order); err != nil {
+		return model.Order{}, fmt.Errorf("decode raw: %w", err)
+	}
+	return order, nil
+}
+
+// patchRaw переносит в raw значения полей ms из norm. Числа и поля вне модели
+// заказа сохраняются как были.
+func patchRaw(raw json.RawMessage, norm model.Order, ms []Mismatch) (json.RawMessage, error) {
+	doc, err := decodeTree(raw)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("raw is not a JSON object")
+	}
+	src := toTree(norm)
+	for _, m := range ms {
+		setPath(root, m.path, getPath(src, m.path))
+	}
+	return json.Marshal(root)
+}
+
+// toTree переводит заказ в дерево map/[]any/json.Number, как decodeTree.
+func toTree(order model.Order) any {
+	b, _ := json.Marshal(order) // model.Order всегда сериализуется
+	tree, _ := decodeTree(b)
+	return tree
+}
+
+func decodeTree(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decode raw: %w", err)
+	}
+	return v, nil
+}
+
+// diff сравнивает два дерева одной формы (оба получены из model.Order).
+func diff(path []string, a, b any) []Mismatch {
+	switch av := a.(type) {
+	case map[string]any:
+		bv := b.(map[string]any)
+		var ms []Mismatch
+		for _, k := range sortedKeys(av) {
+			ms = append(ms, diff(append(slices.Clip(path), k), av[k], bv[k])...)
+		}
+		return ms
+	case []any:
+		bv, _ := b.([]any) // nil-срез сериализуется как null
+		if len(av) != len(bv) {
+			return []Mismatch{{Field: fieldName(path), Raw: len(av), Normalized: len(bv), path: path}}
+		}
+		var ms []Mismatch
+		for i := range av {
+			ms = append(ms, diff(append(slices.Clip(path), strconv.Itoa(i)), av[i], bv[i])...)
+		}
+		return ms
+	}
+	if a == nil && b != nil {
+		if bs, ok := b.([]any); ok && len(bs) == 0 {
+			return nil
+		}
+	}
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	m := Mismatch{Field: fieldName(path), Raw: a, Normalized: b, path: path}
+	if slices.Contains(piiFields, m.Field) {
+		m.Raw, m.Normalized, m.Redacted = nil, nil, true
+	}
+	return []Mismatch{m}
+}
+
+// fieldName собирает путь в вид "items[1].price".
+func fieldName(path []string) string {
+	var sb strings.Builder
+	for i, p := range path {
+		if _, err := strconv.Atoi(p); err == nil {
+			sb.WriteString("[" + p + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+func getPath(v any, path []string) any {
+	for _, p := range path {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[p]
+		case []any:
+			i, _ := strconv.Atoi(p)
+			if i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// setPath записывает value по path, создавая недостающие объекты; позиции
+// массива, которой нет, не создаёт — такое расхождение заменяет массив целиком.
+func setPath(root map[string]any, path []string, value any) {
+	var node any = root
+	for i, p := range path {
+		last := i == len(path)-1
+		switch n := node.(type) {
+		case map[string]any:
+			if last {
+				n[p] = value
+				return
+			}
+			switch n[p].(type) {
+			case map[string]any, []any:
+			default:
+				n[p] = map[string]any{}
+			}
+			node = n[p]
+		case []any:
+			idx, err := strconv.Atoi(p)
+			if err != nil || idx >= len(n) {
+				return
+			}
+			if last {
+				n[idx] = value
+				return
+			}
+			if _, ok := n[idx].(map[string]any); !ok {
+				n[idx] = map[string]any{}
+			}
+			node = n[idx]
+		default:
+			return
+		}
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
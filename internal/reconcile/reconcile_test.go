@@ -0,0 +1,175 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"L0/internal/db"
+	"L0/internal/model"
+)
+
+const rawOrder = `{"order_uid":"order-1","track_number":"WB1","entry":"WBIL","date_created":"2021-11-26T09:22:19+03:00","sm_id":99,"extra":{"note":"kept"},` +
+	`"delivery":{"name":"Test Testov","phone":"+9720000000","city":"Kiryat Mozkin","email":"test@gmail.com","address":"Ploshad Mira 15"},` +
+	`"payment":{"transaction":"t1","amount":1817,"payment_dt":1637907727,"goods_total":317},` +
+	`"items":[{"chrt_id":1,"price":453,"name":"Mascaras"},{"chrt_id":2,"price":100,"name":"Brush"}]}`
+
+// normalized собирает нормализованную форму так, как её пишет SaveOrder.
+func normalized(t *testing.T, raw string) db.OrderPair {
+	t.Helper()
+	var order model.Order
+	if err := json.Unmarshal([]byte(raw), &order); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	order.DateCreated = "2021-11-26T06:22:19Z"
+	return db.OrderPair{OrderUID: order.OrderUID, Raw: json.RawMessage(raw), Normalized: order, HasDelivery: true, HasPayment: true}
+}
+
+func fields(ms []Mismatch) []string {
+	var out []string
+	for _, m := range ms {
+		out = append(out, m.Field)
+	}
+	return out
+}
+
+func TestCompare(t *testing.T) {
+	pair := normalized(t, rawOrder)
+	if ms, err := Compare(pair); err != nil || len(ms) != 0 {
+		t.Fatalf("consistent order: %v %v", ms, err)
+	}
+
+	pair.Normalized.Payment.Amount = 1900
+	pair.Normalized.Items[1].Price = 120
+	pair.Normalized.Delivery.Phone = "+70000000000"
+	pair.Normalized.SmID = 1
+	ms, err := Compare(pair)
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	want := []string{"items[1].price", "payment.amount", "delivery.phone", "sm_id"}
+	if got := fields(ms); len(got) != len(want) || !slices.Equal(slices.Sorted(slices.Values(got)), slices.Sorted(slices.Values(want))) {
+		t.Fatalf("mismatches %v, want %v", got, want)
+	}
+	for _, m := range ms {
+		if m.Field == "delivery.phone" && (!m.Redacted || m.Raw != nil || m.Normalized != nil) {
+			t.Fatalf("personal data leaked into report: %+v", m)
+		}
+	}
+
+	pair = normalized(t, rawOrder)
+	pair.Normalized.Items = pair.Normalized.Items[:1]
+	pair.HasPayment = false
+	pair.Normalized.Payment = model.Payment{}
+	ms, _ = Compare(pair)
+	if got := fields(ms); !slices.Equal(got, []string{"payment", "items"}) {
+		t.Fatalf("mismatches %v", got)
+	}
+}
+
+func TestFixRaw(t *testing.T) {
+	pair := normalized(t, rawOrder)
+	pair.Normalized.Payment.Amount = 1900
+	pair.Normalized.Items[1].Name = "Comb"
+
+	order, raw, err := Fix(pair, RepairRaw)
+	if err != nil {
+		t.Fatalf("fix: %v", err)
+	}
+	if order.Payment.Amount != 1900 || order.Items[1].Name != "Comb" {
+		t.Fatalf("order not patched: %+v", order)
+	}
+	// Поля вне модели и совпадающие значения остаются как были.
+	for _, keep := range []string{`"extra":{"note":"kept"}`, `"date_created":"2021-11-26T09:22:19+03:00"`, `"payment_dt":1637907727`} {
+		if !strings.Contains(string(raw), keep) {
+			t.Fatalf("raw lost %s: %s", keep, raw)
+		}
+	}
+	pair.Raw = raw
+	if ms, _ := Compare(pair); len(ms) != 0 {
+		t.Fatalf("still mismatched after fix: %v", fields(ms))
+	}
+
+	pair.HasDelivery = false
+	if _, _, err := Fix(pair, RepairRaw); err == nil {
+		t.Fatal("expected error: raw cannot be rebuilt without a delivery row")
+	}
+}
+
+// memStore хранит пары в памяти; RepairOrder сохраняет обе формы из того, что вернул fix, как saveOrder.
+type memStore struct {
+	pairs map[string]db.OrderPair
+}
+
+func (s *memStore) ListOrderIDs(ctx context.Context, after string, limit int) ([]string, error) {
+	var ids []string
+	for id := range s.pairs {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids[:min(limit, len(ids))], nil
+}
+
+func (s *memStore) LoadOrderPair(ctx context.Context, uid string) (db.OrderPair, bool, error) {
+	p, ok := s.pairs[uid]
+	return p, ok, nil
+}
+
+func (s *memStore) RepairOrder(ctx context.Context, uid string, fix func(db.OrderPair) (model.Order, json.RawMessage, error)) (bool, error) {
+	p, ok := s.pairs[uid]
+	if !ok {
+		return false, nil
+	}
+	order, raw, err := fix(p)
+	if err != nil || raw == nil {
+		return true, err
+	}
+	order.DateCreated = p.Normalized.DateCreated
+	s.pairs[uid] = db.OrderPair{OrderUID: uid, Raw: raw, Normalized: order, HasDelivery: true, HasPayment: true}
+	return true, nil
+}
+
+func TestRun(t *testing.T) {
+	store := &memStore{pairs: map[string]db.OrderPair{}}
+	for _, uid := range []string{"order-1", "order-2", "order-3"} {
+		store.pairs[uid] = normalized(t, strings.Replace(rawOrder, "order-1", uid, 1))
+	}
+	drifted := store.pairs["order-2"]
+	drifted.Normalized.TrackNumber = "WB2"
+	store.pairs["order-2"] = drifted
+	broken := store.pairs["order-3"]
+	broken.Raw = json.RawMessage(`{"order_uid":"order-3","sm_id":"x"}`)
+	store.pairs["order-3"] = broken
+
+	rep, err := Run(context.Background(), store, Options{Batch: 2})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if rep.Checked != 3 || rep.Mismatched != 1 || rep.Repaired != 0 || rep.Failed != 1 || len(rep.Orders) != 2 {
+		t.Fatalf("report: %+v", rep)
+	}
+	if store.pairs["order-2"].Normalized.TrackNumber != "WB2" {
+		t.Fatal("report-only run changed the order")
+	}
+
+	rep, err = Run(context.Background(), store, Options{OrderUIDs: []string{"order-2"}, Repair: RepairNormalized})
+	if err != nil || rep.Repaired != 1 {
+		t.Fatalf("repair: %+v %v", rep, err)
+	}
+	if store.pairs["order-2"].Normalized.TrackNumber != "WB1" {
+		t.Fatal("tables not repaired from raw")
+	}
+
+	rep, err = Run(context.Background(), store, Options{OrderUIDs: []string{"order-1", "order-9"}})
+	if err != nil || rep.Checked != 1 || !slices.Equal(rep.NotFound, []string{"order-9"}) {
+		t.Fatalf("unknown order must be reported: %+v %v", rep, err)
+	}
+
+	if _, err := Run(context.Background(), store, Options{Repair: "both"}); err == nil {
+		t.Fatal("expected error for unknown repair direction")
+	}
+}
@@ -0,0 +1,62 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header — заголовок, в котором идентификатор запроса приходит от клиента
+// или прокси и возвращается в ответе.
+const Header = "X-Request-ID"
+
+// maxLen ограничивает длину идентификатора, принятого от клиента.
+const maxLen = 128
+
+type ctxKey struct{}
+
+// Middleware берёт идентификатор из заголовка X-Request-ID или генерирует новый,
+// кладёт его в контекст запроса и в заголовок ответа.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+// New генерирует случайный идентификатор.
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithID возвращает контекст с идентификатором запроса.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext возвращает идентификатор запроса или пустую строку.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// valid пропускает только непустые печатные ASCII-идентификаторы разумной длины,
+// чтобы клиент не мог подсунуть в логи и ответы что угодно.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var seen string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	cases := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"propagated", "abc-123", true},
+		{"too long", strings.Repeat("a", maxLen+1), false},
+		{"control chars", "abc\n123", false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.incoming != "" {
+			req.Header.Set(Header, tc.incoming)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		got := rec.Header().Get(Header)
+		if got == "" || got != seen {
+			t.Fatalf("%s: header %q, context %q", tc.name, got, seen)
+		}
+		if (got == tc.incoming) != tc.keep {
+			t.Fatalf("%s: unexpected id %q", tc.name, got)
+		}
+	}
+}
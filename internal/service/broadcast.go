@@ -0,0 +1,114 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"L0/internal/cache"
+)
+
+// Event сообщает о заказе, только что сохранённом ProcessIncoming.
+type Event struct {
+	OrderUID        string
+	DeliveryService string
+	Locale          string
+	CustomerID      string
+	Entry           cache.Entry
+}
+
+// Filter отбирает события для подписчика; пустое поле не ограничивает выборку.
+type Filter struct {
+	DeliveryService string
+	Locale          string
+	CustomerID      string
+}
+
+// Match сообщает, подходит ли событие под фильтр.
+func (f Filter) Match(ev Event) bool {
+	return (f.DeliveryService == "" || f.DeliveryService == ev.DeliveryService) &&
+		(f.Locale == "" || f.Locale == ev.Locale) &&
+		(f.CustomerID == "" || f.CustomerID == ev.CustomerID)
+}
+
+// Subscription — подписка на события. Канал C закрывается при отписке
+// или закрытии Broadcaster.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	filter  Filter
+	dropped atomic.Uint64 // события, потерянные из-за переполненного буфера
+}
+
+// Dropped возвращает число событий, не доставленных из-за медленного чтения.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Broadcaster рассылает события всем подписчикам. Буфер каждого подписчика
+// ограничен: если подписчик не успевает читать, новые события для него
+// отбрасываются, а не блокируют обработку сообщений.
+type Broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe создаёт подписку на события, подходящие под filter, с буфером на buffer событий.
+func (b *Broadcaster) Subscribe(buffer int, filter Filter) *Subscription {
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe отменяет подписку и закрывает её канал.
+func (b *Broadcaster) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish отправляет событие всем подписчикам без блокировки.
+func (b *Broadcaster) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if !sub.filter.Match(ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Close закрывает все подписки; новые подписки сразу получают закрытый канал.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.ch)
+	}
+	b.subs = nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import "testing"
+
+func TestBroadcasterDeliversToAllSubscribers(t *testing.T) {
+	b := NewBroadcaster()
+	first := b.Subscribe(1, Filter{})
+	second := b.Subscribe(1, Filter{})
+
+	b.Publish(Event{OrderUID: "order-1"})
+
+	for _, sub := range []*Subscription{first, second} {
+		ev := <-sub.C
+		if ev.OrderUID != "order-1" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	}
+}
+
+func TestBroadcasterDropsForSlowSubscriber(t *testing.T) {
+	b := NewBroadcaster()
+	sub := b.Subscribe(1, Filter{})
+
+	b.Publish(Event{OrderUID: "order-1"})
+	b.Publish(Event{OrderUID: "order-2"}) // буфер полон — событие теряется, Publish не блокируется
+
+	if ev := <-sub.C; ev.OrderUID != "order-1" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if sub.Dropped() != 1 {
+		t.Fatalf("expected 1 dropped event, got %d", sub.Dropped())
+	}
+}
+
+func TestBroadcasterUnsubscribeAndClose(t *testing.T) {
+	b := NewBroadcaster()
+	sub := b.Subscribe(1, Filter{})
+
+	b.Unsubscribe(sub)
+	b.Unsubscribe(sub)
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected closed channel after unsubscribe")
+	}
+
+	other := b.Subscribe(1, Filter{})
+	b.Close()
+	if _, ok := <-other.C; ok {
+		t.Fatal("expected closed channel after close")
+	}
+	if _, ok := <-b.Subscribe(1, Filter{}).C; ok {
+		t.Fatal("expected closed channel for subscription after close")
+	}
+	b.Publish(Event{OrderUID: "order-1"})
+}
+
+func TestBroadcasterFilter(t *testing.T) {
+	b := NewBroadcaster()
+	meest := b.Subscribe(2, Filter{DeliveryService: "meest"})
+	enTest := b.Subscribe(2, Filter{Locale: "en", CustomerID: "test"})
+
+	b.Publish(Event{OrderUID: "order-1", DeliveryService: "meest", Locale: "ru", CustomerID: "test"})
+	b.Publish(Event{OrderUID: "order-2", DeliveryService: "dhl", Locale: "en", CustomerID: "test"})
+	b.Close()
+
+	var got []string
+	for ev := range meest.C {
+		got = append(got, ev.OrderUID)
+	}
+	if len(got) != 1 || got[0] != "order-1" {
+		t.Fatalf("delivery_service filter: got %v", got)
+	}
+
+	got = nil
+	for ev := range enTest.C {
+		got = append(got, ev.OrderUID)
+	}
+	if len(got) != 1 || got[0] != "order-2" {
+		t.Fatalf("locale+customer filter: got %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Доменные ошибки сервиса. Конкретные ошибки оборачивают их через %w,
+// поэтому транспортный слой различает их с помощью errors.Is.
+var (
+	// ErrOrderNotFound — заказа с таким order_uid нет.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidID — идентификатор заказа пуст или имеет недопустимый формат.
+	ErrInvalidID = errors.New("invalid order id")
+	// ErrValidationFailed — заказ не прошёл валидацию (при приёме или после изменения).
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrInvalidPatch — тело запроса не является корректным merge-patch документом.
+	ErrInvalidPatch = errors.New("invalid merge patch")
+	// ErrPreconditionFailed — If-Match не совпал с текущей версией заказа.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrStorageUnavailable — БД не ответила или вернула ошибку.
+	ErrStorageUnavailable = errors.New("storage unavailable")
+)
+
+// validationError оборачивает причину в ErrValidationFailed.
+func validationError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrValidationFailed, fmt.Sprintf(format, args...))
+}
+
+// storageError оборачивает ошибку БД в ErrStorageUnavailable, сохраняя исходную причину.
+func storageError(err error) error {
+	return fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+}
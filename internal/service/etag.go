@@ -0,0 +1,41 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ETag вычисляет сильный ETag по нормализованному JSON заказа.
+func ETag(normalized []byte) string {
+	sum := sha256.Sum256(normalized)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// VariantETag возвращает ETag для другого представления того же заказа
+// (сжатого или отформатированного): у разных байтов должны быть разные сильные ETag.
+func VariantETag(etag, variant string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + variant + `"`
+}
+
+// baseETag отбрасывает суффикс представления, добавленный VariantETag.
+func baseETag(etag string) string {
+	if i := strings.IndexByte(etag, '-'); i > 0 && strings.HasPrefix(etag, `"`) {
+		return etag[:i] + `"`
+	}
+	return etag
+}
+
+// ifMatch проверяет значение заголовка If-Match по правилам сильного сравнения
+// (RFC 9110, раздел 13.1.1): слабые теги никогда не совпадают, "*" совпадает с любым,
+// а ETag любого представления заказа совпадает с ETag его нормализованного JSON.
+// Замаскированные представления сюда не относятся: их ETag считается по самим байтам.
+func ifMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || baseETag(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
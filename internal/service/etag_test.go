@@ -0,0 +1,56 @@
+package service
+
+import "testing"
+
+func TestETagStable(t *testing.T) {
+	a := ETag([]byte(`{"order_uid":"1"}`))
+	b := ETag([]byte(`{"order_uid":"1"}`))
+	c := ETag([]byte(`{"order_uid":"2"}`))
+
+	if a != b {
+		t.Fatalf("expected equal etags, got %s and %s", a, b)
+	}
+	if a == c {
+		t.Fatal("expected different etags for different payloads")
+	}
+	if a[0] != '"' || a[len(a)-1] != '"' {
+		t.Fatalf("etag must be quoted: %s", a)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	etag := ETag([]byte(`{}`))
+
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{etag, true},
+		{"*", true},
+		{`"other", ` + etag, true},
+		{`"other"`, false},
+		{"W/" + etag, false},
+		{VariantETag(etag, "gzip"), true},
+		{VariantETag(ETag([]byte(`[]`)), "gzip"), false},
+	}
+	for _, tc := range cases {
+		if got := ifMatch(tc.header, etag); got != tc.want {
+			t.Fatalf("ifMatch(%q) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestVariantETag(t *testing.T) {
+	etag := ETag([]byte(`{}`))
+	gz := VariantETag(etag, "gzip")
+
+	if gz == etag {
+		t.Fatal("variant etag must differ from base")
+	}
+	if gz[0] != '"' || gz[len(gz)-1] != '"' {
+		t.Fatalf("variant etag must be quoted: %s", gz)
+	}
+	if baseETag(gz) != etag {
+		t.Fatalf("baseETag(%s) = %s, want %s", gz, baseETag(gz), etag)
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IDFormat описывает допустимый формат order_uid и customer_id в запросах API.
+// Проверка выполняется до обращения к кэшу и БД; заказы из очереди ею не
+// ограничиваются — их формат задаёт поставщик.
+type IDFormat struct {
+	// MaxLen — максимальная длина идентификатора в байтах.
+	MaxLen int
+	// Charset — допустимые символы; идентификатор не может быть пустым.
+	Charset string
+}
+
+// DefaultIDFormat покрывает order_uid из тестовых данных: латиница, цифры, "-" и "_".
+var DefaultIDFormat = IDFormat{
+	MaxLen:  64,
+	Charset: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_",
+}
+
+// Validate возвращает ErrInvalidID, если id пуст, слишком длинный или содержит
+// символы вне Charset.
+func (f IDFormat) Validate(id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidID)
+	}
+	if f.MaxLen > 0 && len(id) > f.MaxLen {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidID, f.MaxLen)
+	}
+	for _, r := range id {
+		if !strings.ContainsRune(f.Charset, r) {
+			return fmt.Errorf("%w: unexpected character %q", ErrInvalidID, r)
+		}
+	}
+	return nil
+}
+
+// Option настраивает OrderService.
+type Option func(*OrderService)
+
+// WithIDFormat задаёт формат идентификаторов вместо DefaultIDFormat.
+func WithIDFormat(f IDFormat) Option {
+	return func(s *OrderService) { s.idFormat = f }
+}
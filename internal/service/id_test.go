@@ -0,0 +1,31 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIDFormatValidate(t *testing.T) {
+	f := IDFormat{MaxLen: 8, Charset: "abc123-"}
+
+	for _, id := range []string{"a", "abc-123", "12312312"} {
+		if err := f.Validate(id); err != nil {
+			t.Fatalf("%q rejected: %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "abcd", "a/b", "a b", "123456789", "аbc"} {
+		if err := f.Validate(id); !errors.Is(err, ErrInvalidID) {
+			t.Fatalf("%q: expected ErrInvalidID, got %v", id, err)
+		}
+	}
+}
+
+func TestDefaultIDFormatAcceptsSampleOrder(t *testing.T) {
+	if err := DefaultIDFormat.Validate("b563feb7b2b84b6test"); err != nil {
+		t.Fatalf("sample order_uid rejected: %v", err)
+	}
+	if err := DefaultIDFormat.Validate(strings.Repeat("x", DefaultIDFormat.MaxLen+1)); err == nil {
+		t.Fatal("expected overlong id to be rejected")
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// mergePatch применяет JSON Merge Patch (RFC 7396) к документу target. Числа
+// сохраняются как json.Number, чтобы патч одного поля не менял остальные
+// (целые больше 2^53 и большие значения не проходят через float64).
+func mergePatch(target, patch []byte) ([]byte, error) {
+	var doc any
+	if len(target) > 0 {
+		var err error
+		if doc, err = decodeJSON(target); err != nil {
+			return nil, fmt.Errorf("invalid target: %w", err)
+		}
+	}
+
+	p, err := decodeJSON(patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	return json.Marshal(mergeValue(doc, p))
+}
+
+// decodeJSON разбирает один JSON-документ с числами json.Number; данные после него — ошибка.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON document")
+	}
+	return v, nil
+}
+
+// mergeValue реализует алгоритм MergePatch из раздела 2 RFC 7396.
+func mergeValue(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergeValue(targetObj[key], value)
+	}
+	return targetObj
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// Примеры из приложения A RFC 7396.
+func TestMergePatchRFCExamples(t *testing.T) {
+	cases := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tc := range cases {
+		got, err := mergePatch([]byte(tc.target), []byte(tc.patch))
+		if err != nil {
+			t.Fatalf("merge %s with %s: %v", tc.target, tc.patch, err)
+		}
+		if !jsonEqual(t, got, []byte(tc.want)) {
+			t.Fatalf("merge %s with %s: got %s, want %s", tc.target, tc.patch, got, tc.want)
+		}
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	if _, err := mergePatch([]byte(`{}`), []byte(`{"a":`)); err == nil {
+		t.Fatal("expected error for malformed patch")
+	}
+}
+
+func TestMergePatchTrailingData(t *testing.T) {
+	if _, err := mergePatch([]byte(`{}`), []byte(`{"a":1} {"b":2}`)); err == nil {
+		t.Fatal("expected error for trailing data in patch")
+	}
+}
+
+func TestMergePatchKeepsLargeNumbers(t *testing.T) {
+	target := []byte(`{"locale":"en","items":[{"chrt_id":9007199254740993,"price":1e21}]}`)
+	merged, err := mergePatch(target, []byte(`{"locale":"ru"}`))
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	// Числа вне патча переносятся как записаны, без округления через float64.
+	if want := `{"items":[{"chrt_id":9007199254740993,"price":1e21}],"locale":"ru"}`; string(merged) != want {
+		t.Fatalf("got %s, want %s", merged, want)
+	}
+}
+
+func TestPatchDeliveryAddressKeepsOrderValid(t *testing.T) {
+	merged, err := mergePatch(sampleOrder, []byte(`{"delivery":{"address":"Ploshad Mira 16"},"items":[{"chrt_id":1,"status":300}]}`))
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+
+	order, _, err := decode(merged)
+	if err != nil {
+		t.Fatalf("merged order invalid: %v", err)
+	}
+	if order.Delivery.Address != "Ploshad Mira 16" {
+		t.Fatalf("address not patched: %s", order.Delivery.Address)
+	}
+	if order.Delivery.City == "" {
+		t.Fatal("expected untouched delivery fields to survive")
+	}
+	if len(order.Items) != 1 || order.Items[0].Status != 300 {
+		t.Fatalf("items not replaced: %+v", order.Items)
+	}
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("unmarshal %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("unmarshal %s: %v", b, err)
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return string(ca) == string(cb)
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	id := "b563feb7b2b84b6test"
+	current, err := normalize(sampleOrder)
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		patch string
+		etag  string
+		want  error
+	}{
+		{"stale etag", `{"locale":"ru"}`, `"stale"`, ErrPreconditionFailed},
+		{"uid change", `{"order_uid":"other"}`, "", ErrValidationFailed},
+		{"wrong type", `{"sm_id":"ninety-nine"}`, "", ErrValidationFailed},
+	}
+	for _, tc := range cases {
+		if _, _, _, err := applyPatch(id, sampleOrder, []byte(tc.patch), tc.etag); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	if _, _, _, err := applyPatch(id, sampleOrder, []byte(`{"locale":"ru"}`), ETag(current)); err != nil {
+		t.Fatalf("matching etag rejected: %v", err)
+	}
+}
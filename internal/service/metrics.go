@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Результаты обработки входящего сообщения для меток метрик.
+const (
+	resultProcessed = "processed" // заказ сохранён
+	resultSkipped   = "skipped"   // сообщение некорректно, повтор не поможет
+	resultFailed    = "failed"    // ошибка хранилища
+)
+
+var (
+	messagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "l0_nats_messages_received_total",
+		Help: "Messages received from the NATS orders channel.",
+	})
+	messagesHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "l0_nats_messages_total",
+		Help: "Handled NATS messages by result (processed, skipped or failed).",
+	}, []string{"result"})
+	processingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "l0_order_processing_duration_seconds",
+		Help:    "Time to decode, validate, store and cache an incoming order, by result.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"result"})
+	warmupDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "l0_cache_warmup_duration_seconds",
+		Help: "Duration of the last cache warm-up from the database.",
+	})
+	warmupOrders = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "l0_cache_warmup_orders",
+		Help: "Orders loaded into the cache by the last warm-up.",
+	})
+)
+
+// observeIncoming учитывает обработанное сообщение; вызывается через defer с адресом ошибки.
+func observeIncoming(start time.Time, err *error) {
+	result := resultProcessed
+	switch {
+	case errors.Is(*err, ErrStorageUnavailable):
+		result = resultFailed
+	case *err != nil:
+		result = resultSkipped
+	}
+	messagesHandled.WithLabelValues(result).Inc()
+	processingDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+
+	"L0/internal/auth"
+	"L0/internal/cache"
+	"L0/internal/dto"
+)
+
+// Profiles выбирает профиль маскирования персональных данных для клиента HTTP и
+// gRPC API. Нулевое значение — профилей нет, все клиенты видят заказ целиком.
+type Profiles struct {
+	byName map[string]dto.Profile
+	byRole map[auth.Role]string
+}
+
+// NewProfiles проверяет профили и привязку ролей к ним. byRole задаёт профиль по
+// умолчанию для роли; профиль из API-ключа или claim profile в JWT важнее.
+func NewProfiles(list []dto.Profile, byRole map[auth.Role]string) (Profiles, error) {
+	ps := Profiles{byName: make(map[string]dto.Profile, len(list)), byRole: byRole}
+	for _, p := range list {
+		if err := p.Validate(); err != nil {
+			return Profiles{}, err
+		}
+		ps.byName[p.Name] = p
+	}
+	for role, name := range byRole {
+		if _, ok := ps.byName[name]; !ok {
+			return Profiles{}, fmt.Errorf("role %s: unknown redaction profile %q", role, name)
+		}
+	}
+	return ps, nil
+}
+
+// For возвращает профиль клиента: указанный в API-ключе или JWT, иначе профиль
+// его роли. nil — заказ отдаётся целиком; false — профиль клиента не настроен,
+// и отдавать ему заказ нельзя.
+func (ps Profiles) For(p auth.Principal) (*dto.Profile, bool) {
+	name := p.Profile
+	if name == "" {
+		name = ps.byRole[p.Role]
+	}
+	if name == "" {
+		return nil, true
+	}
+	profile, ok := ps.byName[name]
+	return &profile, ok
+}
+
+// RedactedFor применяет профиль к заказу через Redacted; для nil-профиля заказ
+// возвращается как есть.
+func (s *OrderService) RedactedFor(id string, entry cache.Entry, profile *dto.Profile) (cache.Entry, error) {
+	if profile == nil {
+		return entry, nil
+	}
+	return s.Redacted(id, entry, *profile)
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"encoding/json"
+
+	"L0/internal/cache"
+	"L0/internal/dto"
+)
+
+// Redacted возвращает заказ, обработанный профилем маскирования. Результат
+// кэшируется как вариант заказа и сбрасывается при следующем изменении заказа.
+// ETag считается по отданным байтам: производный от ETag полного заказа выдал бы,
+// что изменились скрытые поля.
+func (s *OrderService) Redacted(id string, entry cache.Entry, p dto.Profile) (cache.Entry, error) {
+	if v, ok := s.cache.GetVariant(id, p.Name, entry.ETag); ok {
+		return v, nil
+	}
+
+	var order dto.Order
+	if err := json.Unmarshal(entry.Data, &order); err != nil {
+		return cache.Entry{}, err
+	}
+	data, err := json.Marshal(p.Apply(order))
+	if err != nil {
+		return cache.Entry{}, err
+	}
+
+	redacted := cache.Entry{Data: data, ETag: ETag(data), ModTime: entry.ModTime}
+	return s.cache.SetVariant(id, p.Name, entry.ETag, redacted), nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"L0/internal/cache"
+	"L0/internal/dto"
+)
+
+func TestRedactedIsCachedPerVersion(t *testing.T) {
+	c := cache.New()
+	s := NewOrderService(nil, c)
+	p := dto.Profile{Name: "reader", Rules: []dto.Rule{{Field: "delivery.phone", Action: dto.ActionDrop}}}
+
+	v1 := cache.Entry{Data: []byte(`{"order_uid":"order-1","delivery":{"phone":"+9720000000"}}`), ETag: `"v1"`}
+	c.Set("order-1", v1)
+
+	got, err := s.Redacted("order-1", v1, p)
+	if err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+	if strings.Contains(string(got.Data), "+972") || got.ETag != ETag(got.Data) {
+		t.Fatalf("unexpected redacted entry: %s %s", got.Data, got.ETag)
+	}
+	if cached, ok := c.GetVariant("order-1", "reader", `"v1"`); !ok || cached.ETag != got.ETag {
+		t.Fatal("expected redacted entry to be cached")
+	}
+
+	// Событие с новой версией заказа не должно получить вариант от старой.
+	v2 := cache.Entry{Data: []byte(`{"order_uid":"order-1","delivery":{"phone":"+9731111111"}}`), ETag: `"v2"`}
+	c.Set("order-1", v2)
+	got, err = s.Redacted("order-1", v2, p)
+	if err != nil || !strings.Contains(string(got.Data), `"order_uid":"order-1"`) {
+		t.Fatalf("expected fresh variant, got %s %v", got.Data, err)
+	}
+	if cached, ok := c.GetVariant("order-1", "reader", `"v2"`); !ok || cached.ETag != got.ETag {
+		t.Fatal("expected variant of the new version to be cached")
+	}
+}
+
+// ETag замаскированного заказа не должен выдавать изменения скрытых полей.
+func TestRedactedETagHidesMaskedFields(t *testing.T) {
+	s := NewOrderService(nil, cache.New())
+	p := dto.Profile{Name: "reader", Rules: []dto.Rule{{Field: "delivery.phone", Action: dto.ActionDrop}}}
+
+	a := cache.Entry{Data: []byte(`{"order_uid":"order-1","delivery":{"phone":"+9720000000"}}`), ETag: `"a"`}
+	b := cache.Entry{Data: []byte(`{"order_uid":"order-1","delivery":{"phone":"+9731111111"}}`), ETag: `"b"`}
+	ra, err := s.Redacted("order-1", a, p)
+	if err != nil {
+		t.Fatalf("redact a: %v", err)
+	}
+	rb, err := s.Redacted("order-1", b, p)
+	if err != nil {
+		t.Fatalf("redact b: %v", err)
+	}
+	if ra.ETag != rb.ETag {
+		t.Fatalf("orders differing only in a masked field got ETags %s and %s", ra.ETag, rb.ETag)
+	}
+}
@@ -3,85 +3,364 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"L0/internal/cache"
 	"L0/internal/db"
 	"L0/internal/dto"
+	"L0/internal/envelope"
+	"L0/internal/logging"
 	"L0/internal/model"
+	"L0/internal/orderpb"
+	"L0/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
+var tracer = otel.Tracer("L0/internal/service")
+
+// Store — хранилище заказов; его реализует *db.DB.
+type Store interface {
+	SaveOrder(ctx context.Context, order model.Order, raw json.RawMessage) (time.Time, bool, error)
+	UpdateOrder(ctx context.Context, orderUID string, update func(current json.RawMessage) (model.Order, json.RawMessage, error)) (time.Time, bool, error)
+	GetAllOrders(ctx context.Context) (map[string]db.StoredOrder, error)
+	ListOrderIDs(ctx context.Context, after string, limit int) ([]string, error)
+	GetOrder(ctx context.Context, orderUID string) (db.StoredOrder, error)
+	OrderExists(ctx context.Context, orderUID string) (bool, error)
+	FindOrderIDs(ctx context.Context, email, phone string) ([]string, error)
+	DeleteOrder(ctx context.Context, orderUID, actor string) (bool, error)
+	EraseCustomer(ctx context.Context, customerID, actor string) ([]string, error)
+}
+
 // OrderService инкапсулирует бизнес-логику сервиса заказов.
 type OrderService struct {
-	db    *db.DB
-	cache *cache.Cache
+	db       Store
+	cache    *cache.Cache
+	events   *Broadcaster
+	idFormat IDFormat
+
+	warmMu sync.Mutex // прогревы не пересекаются: каждый полагается на свой BeginReplace
+}
+
+func NewOrderService(database Store, cache *cache.Cache, opts ...Option) *OrderService {
+	s := &OrderService{db: database, cache: cache, events: NewBroadcaster(), idFormat: DefaultIDFormat}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func NewOrderService(database *db.DB, cache *cache.Cache) *OrderService {
-	return &OrderService{db: database, cache: cache}
+// Events возвращает рассылку событий о сохранённых заказах.
+func (s *OrderService) Events() *Broadcaster {
+	return s.events
 }
 
-// WarmCache загружает все заказы из БД и кэширует их.
+// WarmCache загружает все заказы из БД и заменяет ими содержимое кэша. Заказы,
+// сохранённые, удалённые или обезличенные, пока шло чтение, не перезаписываются
+// прочитанной версией. Одновременные вызовы выполняются по очереди.
 func (s *OrderService) WarmCache(ctx context.Context) (int, error) {
+	s.warmMu.Lock()
+	defer s.warmMu.Unlock()
+
+	start := time.Now()
+	s.cache.BeginReplace()
 	orders, err := s.db.GetAllOrders(ctx)
 	if err != nil {
-		return 0, err
+		s.cache.AbortReplace()
+		return 0, storageError(err)
 	}
 
-	count := 0
-	for id, raw := range orders {
-		normalized, err := normalize(raw)
+	entries := make(map[string]cache.Entry, len(orders))
+	for id, stored := range orders {
+		normalized, err := normalize(stored.Raw)
 		if err != nil {
 			continue
 		}
-		s.cache.Set(id, normalized)
-		count++
+		entries[id] = newEntry(normalized, stored.UpdatedAt)
 	}
-	return count, nil
+	// Заказы, загруженные из снимка, но удалённые из БД, не должны остаться в кэше.
+	s.cache.Replace(entries)
+	warmupDuration.Set(time.Since(start).Seconds())
+	warmupOrders.Set(float64(len(entries)))
+	return len(entries), nil
+}
+
+// Result — итог обработки входящего сообщения.
+type Result struct {
+	OrderUID string
+	// Created — заказа ещё не было в БД; иначе он обновлён.
+	Created bool
 }
 
-// ProcessIncoming обрабатывает входящее сообщение из очереди.
-func (s *OrderService) ProcessIncoming(ctx context.Context, payload []byte) (string, error) {
-	order, normalized, err := decode(payload)
+// ProcessIncoming обрабатывает входящее сообщение из очереди: обычный JSON заказа
+// или конверт envelope с JSON либо protobuf внутри. Если заказ удалось разобрать,
+// его order_uid возвращается и вместе с ошибкой — для логов.
+func (s *OrderService) ProcessIncoming(ctx context.Context, data []byte) (_ Result, err error) {
+	messagesReceived.Inc()
+	defer observeIncoming(time.Now(), &err)
+
+	order, payload, normalized, err := s.decodeIncoming(ctx, data)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
+	// Всё, что логируется дальше при сохранении заказа, уже относится к нему.
+	ctx = logging.With(ctx, "order_uid", order.OrderUID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("order.uid", order.OrderUID))
 
-	if err := s.db.SaveOrder(ctx, order, payload); err != nil {
-		return "", err
+	updatedAt, created, err := s.db.SaveOrder(ctx, order, payload)
+	if err != nil {
+		return Result{OrderUID: order.OrderUID}, storageError(err)
 	}
 
-	s.cache.Set(order.OrderUID, normalized)
-	return order.OrderUID, nil
+	entry := newEntry(normalized, updatedAt)
+	s.cacheSet(ctx, order.OrderUID, entry)
+	s.events.Publish(Event{
+		OrderUID:        order.OrderUID,
+		DeliveryService: order.DeliveryService,
+		Locale:          order.Locale,
+		CustomerID:      order.CustomerID,
+		Entry:           entry,
+	})
+	return Result{OrderUID: order.OrderUID, Created: created}, nil
+}
+
+// CheckIncoming разбирает и проверяет сообщение так же, как ProcessIncoming, но
+// ничего не сохраняет: Created сообщает, был бы заказ создан или обновлён.
+func (s *OrderService) CheckIncoming(ctx context.Context, data []byte) (Result, error) {
+	order, _, _, err := s.decodeIncoming(ctx, data)
+	if err != nil {
+		return Result{}, err
+	}
+	exists, err := s.db.OrderExists(ctx, order.OrderUID)
+	if err != nil {
+		return Result{OrderUID: order.OrderUID}, storageError(err)
+	}
+	return Result{OrderUID: order.OrderUID, Created: !exists}, nil
 }
 
-// GetByID возвращает заказ из кэша или БД.
-func (s *OrderService) GetByID(ctx context.Context, id string) (json.RawMessage, error) {
-	if data, ok := s.cache.Get(id); ok {
-		return data, nil
+// GetByID возвращает заказ из кэша или БД. Если заказа нет — ErrOrderNotFound,
+// если id не соответствует формату — ErrInvalidID.
+func (s *OrderService) GetByID(ctx context.Context, id string) (cache.Entry, error) {
+	if err := s.idFormat.Validate(id); err != nil {
+		return cache.Entry{}, err
 	}
+	return s.get(ctx, id)
+}
 
-	raw, err := s.db.GetOrder(ctx, id)
-	if err != nil || raw == nil {
-		return nil, err
+// get ищет заказ в кэше, а при промахе загружает его из БД.
+func (s *OrderService) get(ctx context.Context, id string) (cache.Entry, error) {
+	if entry, ok := s.cache.Get(id); ok {
+		return entry, nil
 	}
 
-	normalized, err := normalize(raw)
+	stored, err := s.db.GetOrder(ctx, id)
 	if err != nil {
-		return nil, err
+		return cache.Entry{}, storageError(err)
+	}
+	if stored.Raw == nil {
+		return cache.Entry{}, ErrOrderNotFound
+	}
+
+	normalized, err := normalize(stored.Raw)
+	if err != nil {
+		return cache.Entry{}, err
+	}
+
+	entry := newEntry(normalized, stored.UpdatedAt)
+	s.cacheSet(ctx, id, entry)
+	return entry, nil
+}
+
+// ListOrders возвращает до limit заказов с order_uid больше after в порядке order_uid
+// и их order_uid (ids[i] соответствует entries[i]). Сами заказы берутся из кэша с
+// подгрузкой из БД. next — after для следующей страницы; пусто — страница последняя.
+func (s *OrderService) ListOrders(ctx context.Context, after string, limit int) (ids []string, entries []cache.Entry, next string, err error) {
+	if limit <= 0 {
+		return nil, nil, "", validationError("limit must be positive, got %d", limit)
+	}
+	// Лишний order_uid показывает, есть ли следующая страница, без пустого запроса.
+	ids, err = s.db.ListOrderIDs(ctx, after, limit+1)
+	if err != nil {
+		return nil, nil, "", storageError(err)
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		next = ids[limit-1]
+	}
+
+	found := make([]string, 0, len(ids))
+	entries = make([]cache.Entry, 0, len(ids))
+	for _, id := range ids {
+		entry, err := s.get(ctx, id)
+		if errors.Is(err, ErrOrderNotFound) { // заказ могли удалить между запросами
+			continue
+		}
+		if err != nil {
+			return nil, nil, "", err
+		}
+		found = append(found, id)
+		entries = append(entries, entry)
+	}
+	return found, entries, next, nil
+}
+
+// FindOrders ищет заказы по email и/или телефону доставки и возвращает их order_uid.
+// Персональные данные могут храниться зашифрованными, поэтому ищется только точное совпадение.
+func (s *OrderService) FindOrders(ctx context.Context, email, phone string) ([]string, error) {
+	if email == "" && phone == "" {
+		return nil, validationError("email or phone is required")
+	}
+	ids, err := s.db.FindOrderIDs(ctx, email, phone)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return ids, nil
+}
+
+// PatchOrder применяет JSON Merge Patch к сохранённому заказу, заново валидирует
+// результат и сохраняет его в БД и кэш. Если expectedETag не пуст, изменение
+// выполняется только при совпадении с текущим ETag (If-Match).
+func (s *OrderService) PatchOrder(ctx context.Context, id string, patch []byte, expectedETag string) (cache.Entry, error) {
+	if err := s.idFormat.Validate(id); err != nil {
+		return cache.Entry{}, err
+	}
+	if !json.Valid(patch) {
+		return cache.Entry{}, ErrInvalidPatch
+	}
+
+	// Ошибки из колбэка — доменные, остальные ошибки UpdateOrder относятся к БД.
+	var normalized json.RawMessage
+	var patchErr error
+	updatedAt, found, err := s.db.UpdateOrder(ctx, id, func(current json.RawMessage) (model.Order, json.RawMessage, error) {
+		order, merged, norm, err := applyPatch(id, current, patch, expectedETag)
+		patchErr, normalized = err, norm
+		return order, merged, err
+	})
+	if patchErr != nil {
+		return cache.Entry{}, patchErr
+	}
+	if err != nil {
+		return cache.Entry{}, storageError(err)
+	}
+	if !found {
+		return cache.Entry{}, ErrOrderNotFound
+	}
+
+	entry := newEntry(normalized, updatedAt)
+	s.cacheSet(ctx, id, entry)
+	return entry, nil
+}
+
+// applyPatch проверяет If-Match, применяет merge-patch к текущему raw и валидирует результат.
+func applyPatch(id string, current json.RawMessage, patch []byte, expectedETag string) (model.Order, json.RawMessage, json.RawMessage, error) {
+	if expectedETag != "" {
+		currentNormalized, err := normalize(current)
+		if err != nil {
+			return model.Order{}, nil, nil, err
+		}
+		if !ifMatch(expectedETag, ETag(currentNormalized)) {
+			return model.Order{}, nil, nil, ErrPreconditionFailed
+		}
 	}
 
-	s.cache.Set(id, normalized)
-	return normalized, nil
+	merged, err := mergePatch(current, patch)
+	if err != nil {
+		return model.Order{}, nil, nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	order, normalized, err := decode(merged)
+	if err != nil {
+		return model.Order{}, nil, nil, err
+	}
+	if order.OrderUID != id {
+		return model.Order{}, nil, nil, validationError("order_uid cannot be changed")
+	}
+	return order, merged, normalized, nil
+}
+
+// DeleteOrder удаляет заказ из БД и кэша. Если заказа нет — ErrOrderNotFound.
+func (s *OrderService) DeleteOrder(ctx context.Context, id, actor string) error {
+	if err := s.idFormat.Validate(id); err != nil {
+		return err
+	}
+	deleted, err := s.db.DeleteOrder(ctx, id, actor)
+	if err != nil {
+		return storageError(err)
+	}
+	s.cache.Delete(id)
+	if !deleted {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+// EraseCustomer обезличивает персональные данные покупателя во всех его заказах
+// и сбрасывает их из кэша, чтобы следующий запрос перечитал данные из БД.
+func (s *OrderService) EraseCustomer(ctx context.Context, customerID, actor string) (int, error) {
+	if err := s.idFormat.Validate(customerID); err != nil {
+		return 0, err
+	}
+	ids, err := s.db.EraseCustomer(ctx, customerID, actor)
+	if err != nil {
+		return 0, storageError(err)
+	}
+	for _, id := range ids {
+		s.cache.Delete(id)
+	}
+	return len(ids), nil
+}
+
+// decodeIncoming распаковывает конверт, разбирает и проверяет заказ из сообщения.
+func (s *OrderService) decodeIncoming(ctx context.Context, data []byte) (order model.Order, payload []byte, normalized json.RawMessage, err error) {
+	_, span := tracer.Start(ctx, "decode")
+	defer func() { tracing.End(span, err) }()
+
+	payload, err = orderJSON(envelope.Unwrap(data))
+	if err != nil {
+		return model.Order{}, nil, nil, err
+	}
+	order, normalized, err = decode(payload)
+	if err != nil {
+		return model.Order{}, nil, nil, err
+	}
+	return order, payload, normalized, nil
+}
+
+// cacheSet кладёт заказ в кэш в отдельном спане.
+func (s *OrderService) cacheSet(ctx context.Context, id string, entry cache.Entry) {
+	_, span := tracer.Start(ctx, "cache.Set", trace.WithAttributes(attribute.String("order.uid", id)))
+	defer span.End()
+	s.cache.Set(id, entry)
+}
+
+// orderJSON приводит содержимое конверта к JSON заказа, который хранится в raw.
+func orderJSON(env envelope.Envelope) ([]byte, error) {
+	switch env.ContentType {
+	case envelope.ContentTypeJSON:
+		return env.Payload, nil
+	case envelope.ContentTypeProtobuf:
+		var pb orderpb.Order
+		if err := proto.Unmarshal(env.Payload, &pb); err != nil {
+			return nil, validationError("invalid protobuf: %v", err)
+		}
+		return json.Marshal(dto.FromProto(&pb))
+	default:
+		return nil, validationError("unsupported content type %q", env.ContentType)
+	}
 }
 
 func decode(raw []byte) (model.Order, json.RawMessage, error) {
 	var order model.Order
 	if err := json.Unmarshal(raw, &order); err != nil {
-		return model.Order{}, nil, fmt.Errorf("invalid json: %w", err)
+		return model.Order{}, nil, validationError("invalid json: %v", err)
 	}
 	if order.OrderUID == "" {
-		return model.Order{}, nil, fmt.Errorf("missing order_uid")
+		return model.Order{}, nil, validationError("missing order_uid")
 	}
 
 	dtoOrder := dto.FromModel(order)
@@ -93,6 +372,10 @@ func decode(raw []byte) (model.Order, json.RawMessage, error) {
 	return order, normalized, nil
 }
 
+func newEntry(normalized json.RawMessage, modTime time.Time) cache.Entry {
+	return cache.Entry{Data: normalized, ETag: ETag(normalized), ModTime: modTime}
+}
+
 func normalize(raw json.RawMessage) (json.RawMessage, error) {
 	var order model.Order
 	if err := json.Unmarshal(raw, &order); err != nil {
@@ -1,11 +1,18 @@
 package service
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"testing"
 
+	"L0/internal/cache"
 	"L0/internal/dto"
+	"L0/internal/envelope"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/protobuf/proto"
 )
 
 //go:embed testdata/model.json
@@ -100,3 +107,101 @@ func TestNormalizeMatchesDecode(t *testing.T) {
 		t.Fatalf("expected normalize consistency")
 	}
 }
+
+func TestOrderJSONFromProtobufEnvelope(t *testing.T) {
+	var source dto.Order
+	if err := json.Unmarshal(sampleOrder, &source); err != nil {
+		t.Fatalf("unmarshal sample: %v", err)
+	}
+	payload, err := proto.Marshal(dto.ToProto(source))
+	if err != nil {
+		t.Fatalf("marshal protobuf: %v", err)
+	}
+	msg, err := envelope.Wrap(envelope.ContentTypeProtobuf, payload, nil)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+
+	raw, err := orderJSON(envelope.Unwrap(msg))
+	if err != nil {
+		t.Fatalf("orderJSON failed: %v", err)
+	}
+	_, normalized, err := decode(raw)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	fromJSON, err := normalize(sampleOrder)
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if string(normalized) != string(fromJSON) {
+		t.Fatalf("protobuf order differs from JSON order:\n%s\n%s", normalized, fromJSON)
+	}
+}
+
+func TestOrderJSONUnsupportedContentType(t *testing.T) {
+	if _, err := orderJSON(envelope.Envelope{ContentType: "text/xml", Payload: []byte("<order/>")}); err == nil {
+		t.Fatal("expected error for unsupported content type")
+	}
+}
+
+func TestDecodeErrorsAreValidationFailures(t *testing.T) {
+	for name, payload := range map[string][]byte{
+		"invalid type": sampleInvalidType,
+		"not json":     []byte("{"),
+	} {
+		if _, _, err := decode(payload); !errors.Is(err, ErrValidationFailed) {
+			t.Fatalf("%s: expected ErrValidationFailed, got %v", name, err)
+		}
+	}
+}
+
+func TestProcessIncomingMetrics(t *testing.T) {
+	s := NewOrderService(nil, cache.New())
+	received := testutil.ToFloat64(messagesReceived)
+	skipped := testutil.ToFloat64(messagesHandled.WithLabelValues(resultSkipped))
+
+	if _, err := s.ProcessIncoming(context.Background(), sampleInvalidType); err == nil {
+		t.Fatal("expected invalid message to be rejected")
+	}
+
+	if got := testutil.ToFloat64(messagesReceived) - received; got != 1 {
+		t.Fatalf("expected 1 received message, got %v", got)
+	}
+	if got := testutil.ToFloat64(messagesHandled.WithLabelValues(resultSkipped)) - skipped; got != 1 {
+		t.Fatalf("expected 1 skipped message, got %v", got)
+	}
+}
+
+func TestCheckIncoming(t *testing.T) {
+	// БД не нужна: некорректное сообщение отклоняется до обращения к ней, а пробный
+	// прогон не попадает в метрики обработки.
+	s := NewOrderService(nil, cache.New())
+	received := testutil.ToFloat64(messagesReceived)
+
+	if _, err := s.CheckIncoming(context.Background(), sampleInvalidType); !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("expected ErrValidationFailed, got %v", err)
+	}
+	if got := testutil.ToFloat64(messagesReceived) - received; got != 0 {
+		t.Fatalf("dry run counted %v received messages", got)
+	}
+}
+
+// listStore отдаёт фиксированный список order_uid; остальные методы Store не нужны.
+type listStore struct {
+	Store
+	ids []string
+}
+
+func (l listStore) ListOrderIDs(_ context.Context, _ string, limit int) ([]string, error) {
+	return l.ids[:min(limit, len(l.ids))], nil
+}
+
+func TestListOrdersRejectsNonPositiveLimit(t *testing.T) {
+	s := NewOrderService(listStore{ids: []string{"order-1", "order-2"}}, cache.New())
+	for _, limit := range []int{0, -1} {
+		if _, _, _, err := s.ListOrders(context.Background(), "", limit); !errors.Is(err, ErrValidationFailed) {
+			t.Fatalf("limit %d: expected ErrValidationFailed, got %v", limit, err)
+		}
+	}
+}
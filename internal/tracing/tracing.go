@@ -0,0 +1,102 @@
+// Package tracing настраивает OpenTelemetry: экспорт спанов и распространение
+// контекста трассировки через HTTP-заголовки и заголовки конверта сообщения NATS.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортёры спанов.
+const (
+	ExporterNone   = "none"   // спаны не записываются, но контекст трассировки передаётся дальше
+	ExporterStdout = "stdout" // JSON в stdout, для отладки
+	ExporterOTLP   = "otlp"   // OTLP/gRPC, например в локальный OpenTelemetry Collector
+)
+
+// ErrUnknownExporter возвращается для неизвестного имени экспортёра.
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// Config описывает, куда и как отправлять спаны.
+type Config struct {
+	ServiceName string
+	Exporter    string // ExporterNone, ExporterStdout или ExporterOTLP
+	// OTLPEndpoint — адрес коллектора host:port; пустой — из OTEL_EXPORTER_OTLP_ENDPOINT
+	// или localhost:4317.
+	OTLPEndpoint string
+	// OTLPInsecure отключает TLS, как обычно для коллектора на той же машине.
+	OTLPInsecure bool
+}
+
+// Setup регистрирует глобальные TracerProvider и пропагатор W3C Trace Context.
+// Возвращённая функция досылает накопленные спаны и должна вызываться при остановке.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("stdout exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint))
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("otlp exporter: %w", err)
+		}
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownExporter, cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("trace resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Inject записывает контекст трассировки из ctx в заголовки конверта.
+// Если активного спана нет, headers не меняются.
+func Inject(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// Extract восстанавливает контекст трассировки отправителя из заголовков конверта.
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// End завершает спан и при ошибке отмечает его статусом Error.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
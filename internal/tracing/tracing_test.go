@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEnvelopePropagation(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: ExporterNone}); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ctx, publish := tracer.Start(context.Background(), "publish")
+	headers := map[string]string{}
+	Inject(ctx, headers)
+	publish.End()
+	if headers["traceparent"] == "" {
+		t.Fatalf("traceparent not injected: %v", headers)
+	}
+
+	_, process := tracer.Start(Extract(context.Background(), headers), "process")
+	End(process, errors.New("broken order"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	pub, proc := spans[0], spans[1]
+	if proc.Parent().SpanID() != pub.SpanContext().SpanID() || proc.SpanContext().TraceID() != pub.SpanContext().TraceID() {
+		t.Fatal("consumer span must continue the publisher trace")
+	}
+	if proc.Status().Code != codes.Error || len(proc.Events()) != 1 {
+		t.Fatalf("error not recorded: %+v", proc.Status())
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "jaeger"}); !errors.Is(err, ErrUnknownExporter) {
+		t.Fatalf("expected ErrUnknownExporter, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errBlockedAddress — адрес получателя во внутренней сети сервиса.
+var errBlockedAddress = errors.New("address is not allowed for webhooks")
+
+// blockedPrefixes — служебные диапазоны, которые не покрывают методы netip.Addr.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("198.18.0.0/15"), // сети для тестов производительности
+}
+
+// Resolver находит адреса хоста; его реализует *net.Resolver.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// blocked сообщает, что на адрес нельзя отправлять события: loopback, частные,
+// link-local, multicast и прочие адреса, по которым партнёр мог бы достучаться
+// до внутренних сервисов.
+func blocked(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return true
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost разрешает host и отклоняет его, если хотя бы один адрес внутренний.
+func checkHost(ctx context.Context, r Resolver, host string) error {
+	var addrs []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{ip}
+	} else if addrs, err = r.LookupNetIP(ctx, "ip", host); err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, ip := range addrs {
+		if blocked(ip) {
+			return fmt.Errorf("%s resolves to %s: %w", host, ip, errBlockedAddress)
+		}
+	}
+	return nil
+}
+
+// dialControl проверяет адрес перед каждым соединением: DNS-имя подписки могли
+// перенаправить на внутренний адрес уже после проверки в Create.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if blocked(ap.Addr()) {
+		return fmt.Errorf("dial %s: %w", ap.Addr(), errBlockedAddress)
+	}
+	return nil
+}
+
+// newTransport возвращает транспорт, который соединяется только с внешними
+// адресами. Прокси не используется: через него проверку адреса можно обойти.
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialControl}).DialContext
+	return t
+}
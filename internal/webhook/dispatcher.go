@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"L0/internal/db"
+	"L0/internal/logging"
+)
+
+// leaseMargin добавляется к таймауту запроса: столько доставка остаётся за
+// экземпляром, который её взял, прежде чем её сможет взять другой.
+const leaseMargin = 30 * time.Second
+
+// maxErrorLen ограничивает текст ошибки в журнале доставки.
+const maxErrorLen = 512
+
+// pruneInterval — как часто из журнала удаляются завершённые доставки старше Config.Retention.
+const pruneInterval = time.Hour
+
+// pruneBatch — сколько строк журнала удаляется одним запросом.
+const pruneBatch = 1000
+
+// maxDrainLen — сколько байт ответа дочитывается, чтобы соединение вернулось в пул.
+const maxDrainLen = 4 << 10
+
+// Queue — очередь доставок; её реализует *db.DB.
+type Queue interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]db.DueDelivery, error)
+	FinishWebhookDelivery(ctx context.Context, id int64, res db.DeliveryResult) error
+	PruneWebhookDeliveries(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Config — настройки отправки.
+type Config struct {
+	Interval time.Duration // пауза между опросами очереди, когда она пуста
+	Batch    int           // сколько доставок брать за раз; они отправляются параллельно
+	Timeout  time.Duration // таймаут одного запроса к получателю
+	// MaxAttempts — после стольких неудачных попыток доставка помечается failed.
+	MaxAttempts int
+	// Backoff — задержка перед второй попыткой; дальше она удваивается, но не больше MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retention — сколько хранятся доставленные и неудачные доставки; 0 — бессрочно.
+	Retention time.Duration
+}
+
+// Dispatcher отправляет доставки из очереди получателям.
+type Dispatcher struct {
+	queue  Queue
+	cfg    Config
+	client *http.Client
+}
+
+// NewDispatcher создаёт Dispatcher поверх queue.
+func NewDispatcher(queue Queue, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		queue: queue,
+		cfg:   cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: newTransport(),
+			// Редирект считается неудачей: подписанный запрос уходит только на заданный URL.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Run отправляет доставки, пока ctx не отменён. Полная пачка означает, что в
+// очереди остались доставки, и следующая берётся без паузы.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var pruned time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if d.cfg.Retention > 0 && time.Since(pruned) >= pruneInterval {
+			pruned = time.Now()
+			if n, err := d.Prune(ctx); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "webhook deliveries cleanup failed", "deleted", n, "err", err)
+			}
+		}
+		n, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			dispatchErrors.Inc()
+			slog.WarnContext(ctx, "webhook dispatch failed", "sent", n, "err", err)
+		}
+		if err == nil && n == d.cfg.Batch {
+			timer.Reset(0)
+		} else {
+			timer.Reset(d.cfg.Interval)
+		}
+	}
+}
+
+// DispatchOnce берёт одну пачку доставок, отправляет их и записывает итоги.
+// Возвращает число взятых доставок.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	due, err := d.queue.ClaimWebhookDeliveries(ctx, d.cfg.Batch, d.cfg.Timeout+leaseMargin)
+	if err != nil {
+		return 0, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		finishErr error
+	)
+	for _, dd := range due {
+		wg.Go(func() {
+			res := d.deliver(ctx, dd)
+			// Итог записывается и при остановке сервиса: иначе доставка повторится после lease.
+			fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			if err := d.queue.FinishWebhookDelivery(fctx, dd.ID, res); err != nil {
+				mu.Lock()
+				finishErr = fmt.Errorf("finish webhook delivery %d: %w", dd.ID, err)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return len(due), finishErr
+}
+
+// Prune удаляет из журнала доставленные и неудачные доставки старше Config.Retention.
+// Возвращает число удалённых доставок.
+func (d *Dispatcher) Prune(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-d.cfg.Retention)
+	var total int64
+	for {
+		n, err := d.queue.PruneWebhookDeliveries(ctx, before, pruneBatch)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("prune webhook deliveries: %w", err)
+		}
+		if n < pruneBatch {
+			return total, nil
+		}
+	}
+}
+
+// deliver выполняет одну попытку и решает, что делать с доставкой дальше.
+func (d *Dispatcher) deliver(ctx context.Context, dd db.DueDelivery) db.DeliveryResult {
+	ctx = logging.With(ctx, "webhook_url", dd.URL, "delivery_id", dd.ID, "event_id", dd.Event.ID, "attempt", dd.Attempts)
+	code, err := d.post(ctx, dd)
+	if err == nil {
+		deliveries.WithLabelValues("delivered").Inc()
+		slog.DebugContext(ctx, "webhook delivered", "status_code", code)
+		return db.DeliveryResult{Status: db.DeliveryDelivered, StatusCode: code}
+	}
+
+	res := db.DeliveryResult{StatusCode: code, Error: truncate(err.Error())}
+	if dd.Attempts >= d.cfg.MaxAttempts {
+		res.Status = db.DeliveryFailed
+		deliveries.WithLabelValues("failed").Inc()
+		slog.WarnContext(ctx, "webhook delivery failed, giving up", "status_code", code, "err", err)
+		return res
+	}
+	res.Status = db.DeliveryPending
+	res.NextAttemptAt = time.Now().Add(d.backoff(dd.Attempts))
+	deliveries.WithLabelValues("retry").Inc()
+	slog.InfoContext(ctx, "webhook delivery failed, will retry", "status_code", code, "next_attempt_at", res.NextAttemptAt, "err", err)
+	return res
+}
+
+// post отправляет событие и возвращает код ответа; ошибка — если ответ не 2xx или его не было.
+func (d *Dispatcher) post(ctx context.Context, dd db.DueDelivery) (int, error) {
+	body, err := json.Marshal(dd.Event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dd.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "L0-Webhook/1")
+	req.Header.Set(EventHeader, dd.Event.Type)
+	req.Header.Set(EventIDHeader, strconv.FormatInt(dd.Event.ID, 10))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(dd.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(dd.Secret, time.Now(), body))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	deliveryDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Тело ответа не сохраняется: журнал доставки отдаётся через API, а получатель
+	// мог бы вернуть в нём что угодно.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainLen))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff возвращает задержку после attempts неудачных попыток: Backoff·2^(attempts-1), не больше MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.Backoff
+	for i := 1; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
+
+// truncate обрезает s до maxErrorLen байт и убирает то, что PostgreSQL не примет
+// в TEXT: невалидный UTF-8 (в том числе разрезанный символ) и нулевые байты.
+func truncate(s string) string {
+	if len(s) > maxErrorLen {
+		s = s[:maxErrorLen]
+	}
+	return strings.ReplaceAll(strings.ToValidUTF8(s, ""), "\x00", "")
+}
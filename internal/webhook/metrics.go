@@ -0,0 +1,22 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "l0_webhook_deliveries_total",
+		Help: "Webhook delivery attempts by result (delivered, retry, failed).",
+	}, []string{"result"})
+	deliveryDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "l0_webhook_delivery_duration_seconds",
+		Help:    "Duration of webhook POST requests, including failed ones.",
+		Buckets: prometheus.DefBuckets,
+	})
+	dispatchErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "l0_webhook_dispatch_errors_total",
+		Help: "Failed webhook dispatch rounds (database error).",
+	})
+)
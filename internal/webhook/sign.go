@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки запроса к получателю.
+const (
+	SignatureHeader = "X-L0-Signature" // t=<unix>,v1=<hex HMAC-SHA256>
+	EventHeader     = "X-L0-Event"     // created, updated или deleted
+	EventIDHeader   = "X-L0-Event-ID"  // id события: по нему получатель отбрасывает повторы
+	DeliveryHeader  = "X-L0-Delivery"  // id доставки в журнале
+)
+
+// ErrBadSignature — подпись отсутствует, не совпала или устарела.
+var ErrBadSignature = errors.New("webhook: bad signature")
+
+// Sign возвращает значение заголовка X-L0-Signature для тела body, отправленного в момент t.
+// Подписывается строка "<unix>.<body>", чтобы перехваченный запрос нельзя было
+// повторить позже с другой меткой времени.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify проверяет заголовок X-L0-Signature на стороне получателя. Подпись старше
+// tolerance отклоняется; нулевой tolerance отключает проверку времени.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for part := range strings.SplitSeq(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)).Abs() > tolerance {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,154 @@
+// Package webhook доставляет события о заказах партнёрам по HTTP: подписки
+// хранятся в PostgreSQL, событие попадает в журнал доставки в транзакции
+// изменения заказа, а Dispatcher отправляет его POST-запросом с HMAC-подписью
+// и повторяет с экспоненциальной задержкой, пока получатель не ответит 2xx.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+
+	"L0/internal/db"
+	"L0/internal/service"
+)
+
+var (
+	// ErrNotFound — подписки с таким id нет.
+	ErrNotFound = errors.New("webhook not found")
+	// ErrInvalid — некорректный URL или фильтр событий.
+	ErrInvalid = errors.New("invalid webhook")
+)
+
+// secretPrefix отличает секрет подписи от других ключей в конфигурации партнёра.
+const secretPrefix = "whsec_"
+
+// eventTypes — события, на которые можно подписаться.
+var eventTypes = []string{db.EventCreated, db.EventUpdated, db.EventDeleted}
+
+// defaultEvents — фильтр подписки, если события не указаны.
+var defaultEvents = []string{db.EventCreated, db.EventUpdated}
+
+// Registry — хранилище подписок; его реализует *db.DB.
+type Registry interface {
+	CreateWebhook(ctx context.Context, url, secret string, events []string) (db.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]db.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) (bool, error)
+	WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]db.WebhookDelivery, bool, error)
+}
+
+// Created — только что созданная подписка вместе с секретом: больше он нигде не отдаётся.
+type Created struct {
+	db.Webhook
+	Secret string `json:"secret"`
+}
+
+// Service управляет подписками.
+type Service struct {
+	store    Registry
+	resolver Resolver
+}
+
+// Option настраивает Service.
+type Option func(*Service)
+
+// WithResolver задаёт, как разрешаются хосты URL подписок; по умолчанию — net.DefaultResolver.
+func WithResolver(r Resolver) Option {
+	return func(s *Service) { s.resolver = r }
+}
+
+// NewService создаёт Service поверх store.
+func NewService(store Registry, opts ...Option) *Service {
+	s := &Service{store: store, resolver: net.DefaultResolver}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Create регистрирует подписку на события events (пусто — created и updated)
+// и генерирует для неё секрет подписи. URL, который указывает на внутренние
+// адреса сервиса, отклоняется.
+func (s *Service) Create(ctx context.Context, rawURL string, events []string) (Created, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return Created{}, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalid)
+	}
+	if err := checkHost(ctx, s.resolver, u.Hostname()); err != nil {
+		return Created{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if len(events) == 0 {
+		events = defaultEvents
+	}
+	var filter []string
+	for _, ev := range events {
+		if !slices.Contains(eventTypes, ev) {
+			return Created{}, fmt.Errorf("%w: unknown event %q", ErrInvalid, ev)
+		}
+		if !slices.Contains(filter, ev) {
+			filter = append(filter, ev)
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return Created{}, err
+	}
+	w, err := s.store.CreateWebhook(ctx, u.String(), secret, filter)
+	if err != nil {
+		return Created{}, storageError(err)
+	}
+	return Created{Webhook: w, Secret: secret}, nil
+}
+
+// List возвращает все подписки без секретов.
+func (s *Service) List(ctx context.Context) ([]db.Webhook, error) {
+	ws, err := s.store.ListWebhooks(ctx)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return ws, nil
+}
+
+// Delete удаляет подписку и её журнал доставки.
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	ok, err := s.store.DeleteWebhook(ctx, id)
+	if err != nil {
+		return storageError(err)
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Deliveries возвращает до limit последних доставок подписки, новые первыми.
+func (s *Service) Deliveries(ctx context.Context, id int64, limit int) ([]db.WebhookDelivery, error) {
+	ds, ok, err := s.store.WebhookDeliveries(ctx, id, limit)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ds, nil
+}
+
+// newSecret генерирует случайный секрет подписи.
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+// storageError оборачивает ошибку БД в service.ErrStorageUnavailable: API отвечает на неё 503.
+func storageError(err error) error {
+	return fmt.Errorf("%w: %w", service.ErrStorageUnavailable, err)
+}
@@ -0,0 +1,337 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"L0/internal/db"
+)
+
+// memQueue — очередь доставок в памяти с той же семантикой, что и у *db.DB.
+type memQueue struct {
+	mu    sync.Mutex
+	due   []db.DueDelivery
+	next  map[int64]time.Time
+	state map[int64]db.DeliveryResult
+
+	prunedBefore time.Time
+}
+
+func newMemQueue(ds ...db.DueDelivery) *memQueue {
+	q := &memQueue{due: ds, next: map[int64]time.Time{}, state: map[int64]db.DeliveryResult{}}
+	for _, d := range ds {
+		q.state[d.ID] = db.DeliveryResult{Status: db.DeliveryPending}
+	}
+	return q
+}
+
+func (q *memQueue) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]db.DueDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []db.DueDelivery
+	for i := range q.due {
+		d := &q.due[i]
+		if q.state[d.ID].Status != db.DeliveryPending || q.next[d.ID].After(time.Now()) || len(out) == limit {
+			continue
+		}
+		d.Attempts++
+		q.next[d.ID] = time.Now().Add(lease)
+		out = append(out, *d)
+	}
+	return out, nil
+}
+
+func (q *memQueue) FinishWebhookDelivery(ctx context.Context, id int64, res db.DeliveryResult) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.state[id] = res
+	if res.Status == db.DeliveryPending {
+		q.next[id] = res.NextAttemptAt
+	}
+	return nil
+}
+
+// PruneWebhookDeliveries удаляет завершённые доставки; возраст в памяти не хранится,
+// поэтому before только запоминается для проверки.
+func (q *memQueue) PruneWebhookDeliveries(ctx context.Context, before time.Time, limit int) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prunedBefore = before
+	var n int64
+	q.due = slices.DeleteFunc(q.due, func(d db.DueDelivery) bool {
+		if st := q.state[d.ID].Status; st == db.DeliveryPending || n == int64(limit) {
+			return false
+		}
+		delete(q.state, d.ID)
+		n++
+		return true
+	})
+	return n, nil
+}
+
+// retryNow переносит повторы на текущий момент, чтобы не ждать backoff.
+func (q *memQueue) retryNow() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id := range q.next {
+		q.next[id] = time.Time{}
+	}
+}
+
+func (q *memQueue) result(id int64) db.DeliveryResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.state[id]
+}
+
+// receiver — получатель webhook, который проверяет подпись и отвечает кодами из statuses по очереди.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	events   []string
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := Verify(rv.secret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+		rv.t.Errorf("verify: %v", err)
+	}
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	rv.events = append(rv.events, r.Header.Get(EventIDHeader))
+	status := http.StatusOK
+	if len(rv.statuses) > 0 {
+		status, rv.statuses = rv.statuses[0], rv.statuses[1:]
+	}
+	w.WriteHeader(status)
+	if status != http.StatusOK {
+		io.WriteString(w, "try later")
+	}
+}
+
+func testConfig() Config {
+	return Config{Interval: time.Second, Batch: 10, Timeout: time.Second, MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour}
+}
+
+// newTestDispatcher создаёт Dispatcher, которому разрешено отправлять на
+// httptest-сервер: он слушает loopback, а обычный транспорт такие адреса отклоняет.
+func newTestDispatcher(q Queue) *Dispatcher {
+	d := NewDispatcher(q, testConfig())
+	d.client.Transport = http.DefaultTransport
+	return d
+}
+
+func delivery(id int64, url string) db.DueDelivery {
+	return db.DueDelivery{ID: id, URL: url, Secret: "whsec_test", Event: db.OrderEvent{
+		ID:         7,
+		Type:       db.EventCreated,
+		OrderUID:   "order-1",
+		OccurredAt: time.Now().UTC(),
+		Order:      &db.OrderSummary{TrackNumber: "WB1", Amount: 1817, Items: 1},
+	}}
+}
+
+func TestDispatchRetriesUntilDelivered(t *testing.T) {
+	rv := &receiver{t: t, secret: "whsec_test", statuses: []int{http.StatusInternalServerError}}
+	srv := httptest.NewServer(rv)
+	defer srv.Close()
+	q := newMemQueue(delivery(1, srv.URL))
+	d := newTestDispatcher(q)
+
+	// Первая попытка неудачна: доставка ждёт повтора через Backoff.
+	before := time.Now()
+	if n, err := d.DispatchOnce(context.Background()); n != 1 || err != nil {
+		t.Fatalf("first round: %d, %v", n, err)
+	}
+	res := q.result(1)
+	if res.Status != db.DeliveryPending || res.StatusCode != http.StatusInternalServerError || res.Error == "" {
+		t.Fatalf("after failure: %+v", res)
+	}
+	if res.NextAttemptAt.Before(before.Add(time.Minute)) {
+		t.Fatalf("retry scheduled too early: %v", res.NextAttemptAt)
+	}
+	// Тело ответа получателя в журнал не попадает.
+	if strings.Contains(res.Error, "try later") {
+		t.Fatalf("response body stored: %q", res.Error)
+	}
+
+	// До срока повтора доставка не берётся.
+	if n, _ := d.DispatchOnce(context.Background()); n != 0 {
+		t.Fatalf("claimed %d deliveries before retry time", n)
+	}
+
+	q.retryNow()
+	if n, err := d.DispatchOnce(context.Background()); n != 1 || err != nil {
+		t.Fatalf("second round: %d, %v", n, err)
+	}
+	if res := q.result(1); res.Status != db.DeliveryDelivered || res.StatusCode != http.StatusOK {
+		t.Fatalf("after retry: %+v", res)
+	}
+	// Повтор приходит с тем же id события, чтобы получатель мог отбросить дубль.
+	if len(rv.events) != 2 || rv.events[0] != "7" || rv.events[1] != "7" {
+		t.Fatalf("received events %v", rv.events)
+	}
+}
+
+func TestDispatchGivesUp(t *testing.T) {
+	rv := &receiver{t: t, secret: "whsec_test", statuses: []int{503, 503, 503, 503}}
+	srv := httptest.NewServer(rv)
+	defer srv.Close()
+	q := newMemQueue(delivery(1, srv.URL))
+	d := newTestDispatcher(q)
+
+	for range 3 {
+		if _, err := d.DispatchOnce(context.Background()); err != nil {
+			t.Fatalf("dispatch: %v", err)
+		}
+		q.retryNow()
+	}
+	if res := q.result(1); res.Status != db.DeliveryFailed || res.StatusCode != 503 {
+		t.Fatalf("after max attempts: %+v", res)
+	}
+	if n, _ := d.DispatchOnce(context.Background()); n != 0 {
+		t.Fatalf("failed delivery claimed again")
+	}
+}
+
+func TestDispatchBlocksInternalAddress(t *testing.T) {
+	rv := &receiver{t: t, secret: "whsec_test"}
+	srv := httptest.NewServer(rv)
+	defer srv.Close()
+	q := newMemQueue(delivery(1, srv.URL))
+	d := NewDispatcher(q, testConfig())
+
+	if _, err := d.DispatchOnce(context.Background()); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if res := q.result(1); res.Status != db.DeliveryPending || !strings.Contains(res.Error, errBlockedAddress.Error()) {
+		t.Fatalf("delivery to loopback: %+v", res)
+	}
+	if len(rv.events) != 0 {
+		t.Fatalf("receiver on loopback got %v", rv.events)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	q := newMemQueue(delivery(1, ""), delivery(2, ""), delivery(3, ""))
+	q.FinishWebhookDelivery(context.Background(), 1, db.DeliveryResult{Status: db.DeliveryDelivered})
+	q.FinishWebhookDelivery(context.Background(), 2, db.DeliveryResult{Status: db.DeliveryFailed})
+	cfg := testConfig()
+	cfg.Retention = 24 * time.Hour
+	d := NewDispatcher(q, cfg)
+
+	start := time.Now()
+	if n, err := d.Prune(context.Background()); n != 2 || err != nil {
+		t.Fatalf("prune: %d, %v", n, err)
+	}
+	if cutoff := start.Add(-cfg.Retention); q.prunedBefore.Before(cutoff.Add(-time.Second)) || q.prunedBefore.After(cutoff.Add(time.Second)) {
+		t.Fatalf("pruned before %v, want about %v", q.prunedBefore, cutoff)
+	}
+	if res := q.result(3); res.Status != db.DeliveryPending {
+		t.Fatalf("pending delivery pruned: %+v", res)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, testConfig())
+	for attempts, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 7: time.Hour, 100: time.Hour} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	header := Sign("whsec_a", now, body)
+	if err := Verify("whsec_a", header, body, time.Minute); err != nil {
+		t.Fatalf("valid signature: %v", err)
+	}
+	for name, tc := range map[string]struct {
+		secret, header string
+		body           []byte
+	}{
+		"wrong secret":  {"whsec_b", header, body},
+		"tampered body": {"whsec_a", header, []byte(`{"id":2}`)},
+		"stale":         {"whsec_a", Sign("whsec_a", now.Add(-time.Hour), body), body},
+		"malformed":     {"whsec_a", "v1=abc", body},
+	} {
+		if err := Verify(tc.secret, tc.header, tc.body, time.Minute); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// memRegistry запоминает последнюю созданную подписку.
+type memRegistry struct {
+	Registry
+	created db.Webhook
+}
+
+func (r *memRegistry) CreateWebhook(ctx context.Context, url, secret string, events []string) (db.Webhook, error) {
+	r.created = db.Webhook{ID: 1, URL: url, Secret: secret, Events: events}
+	return r.created, nil
+}
+
+// staticResolver разрешает имена по таблице.
+type staticResolver map[string][]netip.Addr
+
+func (r staticResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func TestCreate(t *testing.T) {
+	reg := &memRegistry{}
+	s := NewService(reg, WithResolver(staticResolver{
+		"partner.example":  {netip.MustParseAddr("203.0.113.10")},
+		"rebound.example":  {netip.MustParseAddr("203.0.113.11"), netip.MustParseAddr("10.0.0.5")},
+		"metadata.example": {netip.MustParseAddr("169.254.169.254")},
+	}))
+
+	w, err := s.Create(context.Background(), "https://partner.example/hooks", nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(w.Secret, secretPrefix) || w.Secret != reg.created.Secret {
+		t.Fatalf("secret %q, stored %q", w.Secret, reg.created.Secret)
+	}
+	if strings.Join(w.Events, ",") != "created,updated" {
+		t.Fatalf("default events %v", w.Events)
+	}
+
+	for _, tc := range []struct {
+		url    string
+		events []string
+	}{
+		{"ftp://partner.example/hooks", nil},
+		{"/hooks", nil},
+		{"https://partner.example/hooks", []string{"created", "paid"}},
+		{"http://127.0.0.1:8081/hooks", nil},
+		{"http://[::1]/hooks", nil},
+		{"http://[::ffff:192.168.1.1]/hooks", nil},
+		{"http://rebound.example/hooks", nil},
+		{"http://metadata.example/latest", nil},
+		{"https://unknown.example/hooks", nil},
+	} {
+		if _, err := s.Create(context.Background(), tc.url, tc.events); !errors.Is(err, ErrInvalid) {
+			t.Errorf("create %q %v: %v", tc.url, tc.events, err)
+		}
+	}
+}
@@ -11,8 +11,13 @@ CREATE TABLE IF NOT EXISTS orders (
     date_created TIMESTAMPTZ,
     oof_shard TEXT,
     raw JSONB NOT NULL,
-    created_at TIMESTAMPTZ DEFAULT now()
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
 );
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT now();
+-- Ключ данных заказа, зашифрованный мастер-ключом dek_key_id (NULL — заказ хранится открытым текстом).
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dek BYTEA;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS dek_key_id TEXT;
 CREATE TABLE IF NOT EXISTS deliveries (
     order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
     name TEXT,
@@ -51,4 +56,66 @@ CREATE TABLE IF NOT EXISTS items (
     brand TEXT,
     status INT
 );
-CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items(order_uid);
\ No newline at end of file
+-- Слепые индексы (HMAC) для поиска по зашифрованным email и телефону.
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS email_bidx TEXT;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS phone_bidx TEXT;
+CREATE INDEX IF NOT EXISTS idx_deliveries_email_bidx ON deliveries(email_bidx);
+CREATE INDEX IF NOT EXISTS idx_deliveries_phone_bidx ON deliveries(phone_bidx);
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items(order_uid);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id);
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    orders_affected INT NOT NULL,
+    actor TEXT,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+-- Исходящие события о заказах (transactional outbox): пишутся в одной транзакции
+-- с изменением заказа, relay публикует их в NATS и проставляет sent_at.
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    order_uid TEXT NOT NULL,
+    summary JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_order_events_unsent ON order_events(id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_order_events_sent ON order_events(created_at) WHERE sent_at IS NOT NULL;
+-- Подписки партнёров на события о заказах (исходящие webhooks) и журнал доставки:
+-- строка webhook_deliveries создаётся в транзакции события для каждой подходящей подписки.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- Ключ данных, которым зашифрован secret (NULL — секрет хранится открытым текстом).
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS secret_dek BYTEA;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS secret_key_id TEXT;
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES order_events(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries(event_id);
+-- Таблицы, созданные до ON DELETE CASCADE: журнал доставки удаляется вместе со своим событием.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'webhook_deliveries_event_id_fkey' AND confdeltype <> 'c') THEN
+        ALTER TABLE webhook_deliveries DROP CONSTRAINT webhook_deliveries_event_id_fkey;
+        ALTER TABLE webhook_deliveries ADD CONSTRAINT webhook_deliveries_event_id_fkey
+            FOREIGN KEY (event_id) REFERENCES order_events(id) ON DELETE CASCADE;
+    END IF;
+END $$;